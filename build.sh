@@ -2,7 +2,9 @@
 cd -- "$(dirname -- "$0")"
 VCS="-buildvcs=false"
 go version
-go get main
+go mod download
 set -x
 env GOOS=windows GOARCH=amd64 CGO_ENABLED=0 CC=x86_64-w64-mingw32-gcc go build -o bin/gofspy.exe $VCS
 env GOOS=windows GOARCH=386 CGO_ENABLED=0 CC=x86_64-w64-mingw32-gcc go build -o bin/gofspy32.exe $VCS
+env GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -o bin/gofspy $VCS
+env GOOS=darwin GOARCH=arm64 CGO_ENABLED=0 go build -o bin/gofspy-darwin $VCS
@@ -1,4 +1,4 @@
-module main
+module github.com/charlesgargasson/gofspy
 
 go 1.24.1
 
@@ -0,0 +1,145 @@
+// Package ipc lists the Unix domain sockets and FIFOs of a Linux system,
+// the local equivalent of Windows named pipes.
+//
+// Sockets come from /proc/net/unix, FIFOs from a scan of given directories,
+// and the processes holding them from /proc/*/fd. Other systems get
+// ErrUnsupported, Supported tells which one is built.
+package ipc
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("Unix sockets and FIFOs are only listed on Linux")
+
+type Kind string
+
+const (
+	Socket Kind = "socket"
+	FIFO   Kind = "fifo"
+)
+
+// Endpoint is a named socket or a FIFO
+type Endpoint struct {
+	Name      string // path, or @name in the abstract namespace
+	Kind      Kind
+	Type      string   // stream, dgram or seqpacket, sockets only
+	Listening bool     // a stream socket accepts connections on Name
+	Inodes    []uint64 // sockets bound to Name, the listening one first
+}
+
+// Abstract sockets have no file, anyone in the network namespace can connect
+func (e Endpoint) Abstract() bool {
+	return strings.HasPrefix(e.Name, "@")
+}
+
+// Holder key of the endpoint in the map of Holders
+func (e Endpoint) keys() []string {
+	if e.Kind == FIFO {
+		return []string{e.Name}
+	}
+	keys := make([]string, len(e.Inodes))
+	for i, inode := range e.Inodes {
+		keys[i] = "socket:[" + strconv.FormatUint(inode, 10) + "]"
+	}
+	return keys
+}
+
+// Info is what Inspect learned about an endpoint
+type Info struct {
+	Endpoint
+	Connect bool // a socket accepts our connection, or a FIFO opens one way at least
+	Read    bool
+	Write   bool
+	Access  string // RW, R-, -W or --
+	Pid     int    // first process holding it, 0 when none we can see
+	Uid     int    // owner of the file, -1 for abstract sockets
+}
+
+// Socket types of /proc/net/unix
+var socketTypes = map[string]string{"0001": "stream", "0002": "dgram", "0005": "seqpacket"}
+
+// __SO_ACCEPTCON, set on listening sockets
+const acceptFlag = 0x10000
+
+// parseUnix reads /proc/net/unix, one endpoint per name sorted by name
+// Unnamed sockets (socketpair, clients) are skipped
+func parseUnix(r io.Reader) ([]Endpoint, error) {
+	byName := make(map[string]*Endpoint)
+	scanner := bufio.NewScanner(r)
+	// Num RefCount Protocol Flags Type St Inode Path
+	scanner.Scan()
+	for scanner.Scan() {
+		fields, path := cutFields(scanner.Text(), 7)
+		if len(fields) < 7 || path == "" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			continue
+		}
+		listening := flags&acceptFlag != 0
+
+		endpoint := byName[path]
+		if endpoint == nil {
+			endpoint = &Endpoint{Name: path, Kind: Socket, Type: socketTypes[fields[4]]}
+			byName[path] = endpoint
+		}
+		if listening && !endpoint.Listening {
+			endpoint.Listening = true
+			endpoint.Type = socketTypes[fields[4]]
+			endpoint.Inodes = append([]uint64{inode}, endpoint.Inodes...)
+		} else {
+			endpoint.Inodes = append(endpoint.Inodes, inode)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]Endpoint, 0, len(byName))
+	for _, endpoint := range byName {
+		endpoints = append(endpoints, *endpoint)
+	}
+	sortEndpoints(endpoints)
+	return endpoints, nil
+}
+
+// cutFields splits the first n space separated fields of line, the rest is kept as is (paths may hold spaces)
+func cutFields(line string, n int) ([]string, string) {
+	var fields []string
+	rest := strings.TrimLeft(line, " ")
+	for len(fields) < n && rest != "" {
+		end := strings.IndexByte(rest, ' ')
+		if end < 0 {
+			return append(fields, rest), ""
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " ")
+	}
+	return fields, rest
+}
+
+func sortEndpoints(endpoints []Endpoint) {
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Name < endpoints[j].Name })
+}
+
+func displayAccess(read bool, write bool) string {
+	access := "-"
+	if read {
+		access = "R"
+	}
+	if write {
+		return access + "W"
+	}
+	return access + "-"
+}
@@ -0,0 +1,138 @@
+//go:build linux
+
+package ipc
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const Supported = true
+
+var errNotEndpoint = errors.New("not a named socket or FIFO")
+
+// FIFOs deeper than this below a scanned directory are missed
+const scanDepth = 4
+
+// List returns the named sockets of /proc/net/unix and the FIFOs below dirs, sorted by name
+func List(dirs []string) ([]Endpoint, error) {
+	file, err := os.Open("/proc/net/unix")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	endpoints, err := parseUnix(file)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		base := strings.Count(filepath.Clean(dir), string(filepath.Separator))
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if entry.IsDir() && strings.Count(path, string(filepath.Separator))-base >= scanDepth {
+				return filepath.SkipDir
+			}
+			if entry.Type()&fs.ModeNamedPipe != 0 && !seen[path] {
+				seen[path] = true
+				endpoints = append(endpoints, Endpoint{Name: path, Kind: FIFO})
+			}
+			return nil
+		})
+	}
+	sortEndpoints(endpoints)
+	return endpoints, nil
+}
+
+// Lookup finds a single endpoint, a named socket or a FIFO anywhere
+func Lookup(name string) (Endpoint, error) {
+	endpoints, err := List(nil)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.Name == name {
+			return endpoint, nil
+		}
+	}
+	info, err := os.Lstat(name)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if info.Mode()&fs.ModeNamedPipe == 0 {
+		return Endpoint{}, &fs.PathError{Op: "lookup", Path: name, Err: errNotEndpoint}
+	}
+	return Endpoint{Name: name, Kind: FIFO}, nil
+}
+
+// Holders maps the fd targets of every process we can read to the lowest pid holding them
+// socket:[inode] for sockets, the path for FIFOs. Other users' processes need root
+func Holders() (map[string]int, error) {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	holders := make(map[string]int)
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			if holder, found := holders[target]; !found || pid < holder {
+				holders[target] = pid
+			}
+		}
+	}
+	return holders, nil
+}
+
+// Inspect checks our access to endpoint, with access(2) and the real ids so nothing is opened
+// Connecting to a socket needs write on its file, and the socket must accept connections
+func Inspect(endpoint Endpoint, holders map[string]int) Info {
+	info := Info{Endpoint: endpoint, Uid: -1}
+	if !endpoint.Abstract() {
+		var stat unix.Stat_t
+		if err := unix.Stat(endpoint.Name, &stat); err == nil {
+			info.Uid = int(stat.Uid)
+		}
+	}
+
+	switch endpoint.Kind {
+	case Socket:
+		connectable := endpoint.Listening || endpoint.Type == "dgram"
+		info.Connect = connectable && (endpoint.Abstract() || unix.Access(endpoint.Name, unix.W_OK) == nil)
+		info.Read, info.Write = info.Connect, info.Connect
+	case FIFO:
+		info.Read = unix.Access(endpoint.Name, unix.R_OK) == nil
+		info.Write = unix.Access(endpoint.Name, unix.W_OK) == nil
+		info.Connect = info.Read || info.Write
+	}
+	info.Access = displayAccess(info.Read, info.Write)
+
+	for _, key := range endpoint.keys() {
+		if pid, found := holders[key]; found {
+			info.Pid = pid
+			break
+		}
+	}
+	return info
+}
@@ -0,0 +1,94 @@
+package ipc
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func find(endpoints []Endpoint, name string) (Endpoint, bool) {
+	for _, endpoint := range endpoints {
+		if endpoint.Name == name {
+			return endpoint, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+func TestListAndInspect(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "ctl.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("can't listen: %v", err)
+	}
+	defer listener.Close()
+
+	fifoPath := filepath.Join(dir, "sub", "fifo")
+	os.Mkdir(filepath.Dir(fifoPath), 0o755)
+	if err := unix.Mkfifo(fifoPath, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Held open so Holders sees it, non blocking as nobody writes
+	fifo, err := os.OpenFile(fifoPath, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fifo.Close()
+
+	endpoints, err := List([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	holders, err := Holders()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket, found := find(endpoints, socketPath)
+	if !found || socket.Kind != Socket || socket.Type != "stream" || !socket.Listening {
+		t.Fatalf("socket %+v (found %v)", socket, found)
+	}
+	info := Inspect(socket, holders)
+	if info.Pid != os.Getpid() || info.Access != "RW" || !info.Connect || info.Uid != os.Getuid() {
+		t.Errorf("socket info %+v", info)
+	}
+
+	named, found := find(endpoints, fifoPath)
+	if !found || named.Kind != FIFO {
+		t.Fatalf("fifo %+v (found %v)", named, found)
+	}
+	info = Inspect(named, holders)
+	if info.Pid != os.Getpid() || !info.Read || info.Uid != os.Getuid() {
+		t.Errorf("fifo info %+v", info)
+	}
+
+	if endpoint, err := Lookup(fifoPath); err != nil || endpoint.Kind != FIFO {
+		t.Errorf("lookup %+v (%v)", endpoint, err)
+	}
+	if _, err := Lookup(dir); err == nil {
+		t.Errorf("lookup of a directory")
+	}
+}
+
+func TestListDepth(t *testing.T) {
+	dir := t.TempDir()
+	deep := filepath.Join(dir, "a", "b", "c", "d")
+	os.MkdirAll(deep, 0o755)
+	unix.Mkfifo(filepath.Join(dir, "a", "b", "c", "near"), 0o600)
+	unix.Mkfifo(filepath.Join(deep, "far"), 0o600)
+
+	endpoints, err := List([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := find(endpoints, filepath.Join(dir, "a", "b", "c", "near")); !found {
+		t.Errorf("missed a FIFO %d levels down", scanDepth-1)
+	}
+	if _, found := find(endpoints, filepath.Join(deep, "far")); found {
+		t.Errorf("found a FIFO %d levels down", scanDepth)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package ipc
+
+const Supported = false
+
+func List(dirs []string) ([]Endpoint, error) {
+	return nil, ErrUnsupported
+}
+
+func Lookup(name string) (Endpoint, error) {
+	return Endpoint{}, ErrUnsupported
+}
+
+func Holders() (map[string]int, error) {
+	return nil, ErrUnsupported
+}
+
+func Inspect(endpoint Endpoint, holders map[string]int) Info {
+	return Info{Endpoint: endpoint, Uid: -1, Access: "--"}
+}
@@ -0,0 +1,51 @@
+package ipc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Captured from /proc/net/unix, with a path holding a space
+const procNetUnix = `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 20351 /run/dbus/system_bus_socket
+0000000000000000: 00000003 00000000 00000000 0001 03 20990 /run/dbus/system_bus_socket
+0000000000000000: 00000002 00000000 00010000 0001 01 18455 @/tmp/.X11-unix/X0
+0000000000000000: 00000002 00000000 00000000 0002 01 15012 /run/systemd/notify
+0000000000000000: 00000003 00000000 00000000 0001 03 21456
+0000000000000000: 00000002 00000000 00010000 0005 01 30011 /tmp/my app/ctl
+`
+
+func TestParseUnix(t *testing.T) {
+	got, err := parseUnix(strings.NewReader(procNetUnix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Endpoint{
+		{Name: "/run/dbus/system_bus_socket", Kind: Socket, Type: "stream", Listening: true, Inodes: []uint64{20351, 20990}},
+		{Name: "/run/systemd/notify", Kind: Socket, Type: "dgram", Inodes: []uint64{15012}},
+		{Name: "/tmp/my app/ctl", Kind: Socket, Type: "seqpacket", Listening: true, Inodes: []uint64{30011}},
+		{Name: "@/tmp/.X11-unix/X0", Kind: Socket, Type: "stream", Listening: true, Inodes: []uint64{18455}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseUnixListenerAfterClients(t *testing.T) {
+	// Accepted sockets can come before their listener, which is put first
+	table := `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000003 00000000 00000000 0001 03 7 /run/a
+0000000000000000: 00000002 00000000 00010000 0001 01 5 /run/a
+`
+	got, err := parseUnix(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !got[0].Listening || !reflect.DeepEqual(got[0].Inodes, []uint64{5, 7}) {
+		t.Errorf("got %+v", got)
+	}
+	if keys := got[0].keys(); !reflect.DeepEqual(keys, []string{"socket:[5]", "socket:[7]"}) {
+		t.Errorf("keys %q", keys)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/fs"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func (linuxFiles) owner(filePath string) (string, string) {
+	var stat unix.Stat_t
+	err := unix.Stat(filePath, &stat)
+	if err != nil {
+		return "", ""
+	}
+	return accounts.Uid(stat.Uid), strconv.FormatUint(uint64(stat.Uid), 10)
+}
+
+// access uses access(2) with the real ids
+func (linuxFiles) access(path string) (bool, bool) {
+	return unix.Access(path, unix.R_OK) == nil, unix.Access(path, unix.W_OK) == nil
+}
+
+// canWriteDir checks if the current user can create entries in dir
+func (linuxFiles) canWriteDir(dir string) bool {
+	return unix.Access(dir, unix.W_OK|unix.X_OK) == nil
+}
+
+// fileAttributes returns st_mode
+func fileAttributes(info fs.FileInfo) uint32 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Mode
+	}
+	return uint32(info.Mode())
+}
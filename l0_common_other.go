@@ -0,0 +1,39 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+func (otherFiles) owner(filePath string) (string, string) {
+	return "", ""
+}
+
+// access opens path, for reading then for writing
+func (otherFiles) access(path string) (bool, bool) {
+	return canOpen(path, os.O_RDONLY), canOpen(path, os.O_WRONLY)
+}
+
+func canOpen(path string, flag int) bool {
+	file, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
+
+func (otherFiles) canWriteDir(dir string) bool {
+	return false
+}
+
+func (otherFiles) tryRight(path string, kind string, mask uint32) int {
+	return rightUntested
+}
+
+// fileAttributes returns the Go file mode
+func fileAttributes(info fs.FileInfo) uint32 {
+	return uint32(info.Mode())
+}
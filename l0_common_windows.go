@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/fs"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32                  = windows.NewLazyDLL("kernel32.dll")
+	procCreateFile            = kernel32.NewProc("CreateFileW")
+	procCloseHandle           = kernel32.NewProc("CloseHandle")
+	procWaitForSingleObject   = kernel32.NewProc("WaitForSingleObject")
+	procGetNamedPipeClientPID = kernel32.NewProc("GetNamedPipeClientProcessId")
+)
+
+func getHandleOwner(handle windows.Handle, result *fileOwner, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// Get the security descriptor
+	sd, err := windows.GetSecurityInfo(handle, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return
+	}
+
+	// Get the owner SID from the security descriptor
+	ownerSid, _, err := sd.Owner()
+	if err != nil {
+		return
+	}
+
+	// Convert SID to a readable username
+	*result = fileOwner{name: accounts.Sid(ownerSid.String()), id: ownerSid.String()}
+	return
+}
+
+func tryFilePermissions(path string, permissions uint32, success chan bool) {
+
+	// Convert the path to UTF16 format
+	pPath, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		success <- false
+		return
+	}
+
+	handle, err := windows.CreateFile(pPath,
+		permissions,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL,
+		0)
+
+	// We don't need the handle anymore, let's close it now without blocking function
+	go windows.CloseHandle(handle)
+
+	if err == nil {
+		success <- true
+		return
+	}
+	success <- false
+}
+
+// owner opens filePath with READ_CONTROL only
+func (windowsFiles) owner(filePath string) (string, string) {
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(filePath),
+		windows.READ_CONTROL,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+
+	// Close the handle after function return
+	defer windows.CloseHandle(handle)
+
+	if err != nil {
+		return "", ""
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var owner fileOwner
+	go getHandleOwner(handle, &owner, &wg)
+	wg.Wait()
+	return owner.name, owner.id
+}
+
+// access tries GENERIC_READ and GENERIC_WRITE in parallel
+func (windowsFiles) access(path string) (bool, bool) {
+	readSuccess := make(chan bool)
+	writeSuccess := make(chan bool)
+
+	go tryFilePermissions(path, windows.GENERIC_READ, readSuccess)
+	go tryFilePermissions(path, windows.GENERIC_WRITE, writeSuccess)
+
+	return <-readSuccess, <-writeSuccess
+}
+
+// canWriteDir checks if the current user can create files in dir
+func (windowsFiles) canWriteDir(dir string) bool {
+	const FILE_ADD_FILE = 0x0002
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(dir),
+		FILE_ADD_FILE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS,
+		0,
+	)
+	if err != nil {
+		return false
+	}
+	windows.CloseHandle(handle)
+	return true
+}
+
+func GetNamedPipeClientPID(handle windows.Handle, result *uint32, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var buffer uint32
+	ret, _, err := procGetNamedPipeClientPID.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(&buffer)),
+	)
+	if ret == 0 {
+		buffer = 0
+	}
+	if debug {
+		printNotice("[DEBUG] GetNamedPipeClientPID ret:%d err:%v result:%d\n", ret, err, buffer)
+	}
+	*result = buffer
+}
+
+// fileAttributes returns FILE_ATTRIBUTE_* flags
+func fileAttributes(info fs.FileInfo) uint32 {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return data.FileAttributes
+	}
+	return 0
+}
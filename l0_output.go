@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+var jsonOutput bool
+
+// Structured event, printed as one JSON object per line with -json
+type fsEvent struct {
+	Time       time.Time         `json:"time"`
+	Kind       string            `json:"kind"`   // file, dir, pipe
+	Action     string            `json:"action"` // added, removed, modified, renamed, moved_out, moved_in, existing ...
+	Path       string            `json:"path"`
+	OldPath    string            `json:"old_path,omitempty"` // renamed only
+	Access     string            `json:"access"`
+	Owner      string            `json:"owner"`
+	OwnerId    string            `json:"owner_id,omitempty"` // SID or uid
+	Hijackable bool              `json:"hijackable"`
+	Rescan     bool              `json:"rescan,omitempty"` // recovered after an overflow
+	Client     *int              `json:"client,omitempty"`
+	Size       int               `json:"size,omitempty"`
+	Data       string            `json:"data,omitempty"`
+	RawData    []byte            `json:"data_base64,omitempty"` // binary data, not valid UTF-8
+	Error      string            `json:"error,omitempty"`
+	Process    *procInfo         `json:"process,omitempty"`
+	Changes    []string          `json:"changes,omitempty"` // -diff only
+	Hashes     map[string]string `json:"hashes,omitempty"`
+	Capture    string            `json:"capture,omitempty"`    // copy in the evidence directory
+	Risks      []string          `json:"risks,omitempty"`      // privesc heuristics
+	Acl        []aceInfo         `json:"acl,omitempty"`        // -acl only
+	Probe      *probeResult      `json:"probe,omitempty"`      // -probe only
+	Unenriched bool              `json:"unenriched,omitempty"` // dropped by the overloaded enrichment pool
+
+	actionCode uint32
+}
+
+// Process behind a file event (fanotify)
+type procInfo struct {
+	Pid  int    `json:"pid"`
+	Exe  string `json:"exe"`
+	Uid  int    `json:"uid"` // -1 when unknown
+	User string `json:"user"`
+}
+
+// printEvent writes the event as JSON when -json is set, otherwise the given text line
+func printEvent(event fsEvent, format string, a ...any) {
+	if !jsonOutput {
+		fmt.Printf(format, a...)
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[*] Can't encode event (%v)\n", err)
+		return
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
+
+// printNotice writes a line that isn't an event, on stderr when stdout holds JSON
+func printNotice(format string, a ...any) {
+	if jsonOutput {
+		fmt.Fprintf(os.Stderr, format, a...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+func pipeEvent(pipeName string, action string) fsEvent {
+	return fsEvent{
+		Time:   time.Now(),
+		Kind:   "pipe",
+		Action: action,
+		Path:   pipeName,
+	}
+}
+
+func pipeErrorEvent(pipeName string, err error) fsEvent {
+	event := pipeEvent(pipeName, "error")
+	event.Error = err.Error()
+	return event
+}
+
+func pipeDataEvent(pipeName string, action string, data []byte) fsEvent {
+	event := pipeEvent(pipeName, action)
+	event.Size = len(data)
+	if utf8.Valid(data) {
+		event.Data = string(data)
+	} else {
+		event.RawData = data
+	}
+	return event
+}
+
+func clientEvent(event fsEvent, clientID int) fsEvent {
+	event.Client = &clientID
+	return event
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"github.com/charlesgargasson/gofspy/pipes"
+	"github.com/charlesgargasson/gofspy/sddl"
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+// OS specific services, backed by the watch and pipes packages, l0_platform_windows.go and l0_platform_linux.go
+// Other systems get the fallbacks of the _other.go files, without pipes nor owners
+// The core only goes through these, so it builds and runs its tests everywhere
+
+var osWatcher watcher = libraryWatcher{}
+
+// watcher reports the changes below root to dispatchEvent, until ctx is cancelled or the root is gone
+type watcher interface {
+	watch(ctx context.Context, root watch.Root, monitortype int) error
+}
+
+// fileSystem answers access questions for the current user
+type fileSystem interface {
+	// owner returns the account owning path and its SID or uid, empty when it can't be read
+	owner(path string) (name string, id string)
+	// access tells whether path can be opened for reading and for writing
+	access(path string) (read bool, write bool)
+	// tryRight returns rightGranted, rightDenied or rightUntested for a single right
+	tryRight(path string, kind string, mask uint32) int
+	// canWriteDir tells whether entries can be created in dir
+	canWriteDir(dir string) bool
+}
+
+// pipeTransport opens, creates, dials and queries named pipes
+type pipeTransport interface {
+	// open connects to name with access only
+	open(name string, access pipes.Access) (pipeClient, error)
+	// create adds an instance to name, squatting it when the server didn't
+	create(name string) (pipeServer, error)
+	// dial connects to name as a client, waiting for a free instance
+	dial(ctx context.Context, name string) (io.ReadWriteCloser, error)
+	// listen serves name until the listener is closed
+	listen(name string) (net.Listener, error)
+	// inspect opens name with the best access it can get and queries its server
+	inspect(name string) pipeDetails
+	// list returns the full name of every pipe
+	list() ([]string, error)
+}
+
+// pipeClient reads and writes whole messages
+type pipeClient interface {
+	ReadMessage(ctx context.Context) ([]byte, error)
+	WriteMessage(data []byte) error
+	Close() error
+}
+
+// pipeServer is an instance we created, read and write once a client is connected
+type pipeServer interface {
+	io.ReadWriteCloser
+	// Connect waits for a client, cancelling ctx closes the instance
+	Connect(ctx context.Context) error
+}
+
+// pipeDetails is what inspect could learn about a pipe, with names resolved
+type pipeDetails struct {
+	Read      bool
+	Write     bool
+	Control   bool // READ_CONTROL, the queries below need it
+	Access    string
+	Pid       uint32
+	Process   *procInfo // process holding a Unix socket or FIFO, with its user
+	Owner     string
+	OwnerId   string // SID or uid of Owner
+	Acl       []aceInfo
+	State     string // WAIT, NOWAIT, MESSAGE, empty when unknown
+	Instances uint32
+	HasState  bool
+}
+
+// One watch.Watcher per root
+type libraryWatcher struct{}
+
+func (libraryWatcher) watch(ctx context.Context, root watch.Root, monitortype int) error {
+	var watcher *watch.Watcher
+	watcher = watch.New(watch.Options{
+		BufferSize: bufferSize,
+		Names:      accounts.Names,
+		// Listing used to recover lost events, never for pipes
+		Rescan: rescanOnOverflow && monitortype == 0,
+		Warn: func(err error) {
+			printNotice("[*] %v\n", err)
+			// The root stopped on its own (drive unplugged ...)
+			if len(watcher.Roots()) == 0 {
+				go watcher.Close()
+			}
+		},
+	})
+	if err := watcher.Add(root); err != nil {
+		watcher.Close()
+		return err
+	}
+	stopClose := context.AfterFunc(ctx, watcher.Close)
+	defer stopClose()
+
+	for change := range watcher.Events() {
+		dispatchEvent(change, monitortype)
+	}
+	return nil
+}
+
+// The pipes package, ErrUnsupported outside of Windows
+type libraryPipes struct{}
+
+func (libraryPipes) open(name string, access pipes.Access) (pipeClient, error) {
+	client, err := pipes.Open(name, access)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (libraryPipes) create(name string) (pipeServer, error) {
+	instance, err := pipes.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func (libraryPipes) dial(ctx context.Context, name string) (io.ReadWriteCloser, error) {
+	conn, err := pipes.Dial(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (libraryPipes) listen(name string) (net.Listener, error) {
+	return pipes.Listen(name)
+}
+
+// inspect names the owner and decodes the DACL (-acl) of pipes.Check
+func (libraryPipes) inspect(name string) pipeDetails {
+	info := pipes.Check(name)
+	details := pipeDetails{
+		Read:      info.Read,
+		Write:     info.Write,
+		Control:   info.Control,
+		Access:    info.Access,
+		Pid:       info.Pid,
+		State:     info.State,
+		Instances: info.Instances,
+		HasState:  info.HasState,
+	}
+	if info.Owner != "" {
+		details.Owner = sidName(sddl.SID(info.Owner))
+		details.OwnerId = info.Owner
+	}
+	if aclDump && info.Descriptor != "" {
+		if sd, err := sddl.Parse(info.Descriptor); err == nil {
+			details.Acl = descriptorAcl(sd, "pipe")
+		}
+	}
+	return details
+}
+
+func (libraryPipes) list() ([]string, error) {
+	return pipes.List()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charlesgargasson/gofspy/ipc"
+)
+
+var osFiles fileSystem = linuxFiles{}
+
+// Unix sockets and FIFOs stand for named pipes
+var osPipes pipeTransport = &unixPipes{}
+
+// access(2) and stat(2), nothing is opened
+type linuxFiles struct{}
+
+// Directories scanned for FIFOs, sockets are all in /proc/net/unix
+var fifoDirs = []string{"/run", "/tmp", "/var/tmp", "/dev/shm", "/var/spool"}
+
+// Processes holding an endpoint are looked up again after this
+const holdersMaxAge = time.Second
+
+// unixPipes lists Unix sockets and FIFOs as pipes, opening and creating them stays unsupported
+type unixPipes struct {
+	libraryPipes
+
+	sync.Mutex
+	endpoints map[string]ipc.Endpoint // last list
+	holders   map[string]int
+	holdersAt time.Time
+}
+
+func (p *unixPipes) list() ([]string, error) {
+	endpoints, err := ipc.List(fifoDirs)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(endpoints))
+	byName := make(map[string]ipc.Endpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		names[i] = endpoint.Name
+		byName[endpoint.Name] = endpoint
+	}
+	p.Lock()
+	p.endpoints = byName
+	p.Unlock()
+	return names, nil
+}
+
+// inspect names the holder process and the file owner, the owner of abstract sockets is the holder
+func (p *unixPipes) inspect(name string) pipeDetails {
+	p.Lock()
+	endpoint, found := p.endpoints[name]
+	if time.Since(p.holdersAt) > holdersMaxAge {
+		p.holders, _ = ipc.Holders()
+		p.holdersAt = time.Now()
+	}
+	holders := p.holders
+	p.Unlock()
+
+	if !found {
+		var err error
+		if endpoint, err = ipc.Lookup(name); err != nil {
+			return pipeDetails{Access: "--"}
+		}
+	}
+
+	info := ipc.Inspect(endpoint, holders)
+	details := pipeDetails{
+		Read:    info.Read,
+		Write:   info.Write,
+		Control: true, // /proc answers without a handle
+		Access:  info.Access,
+		Pid:     uint32(info.Pid),
+	}
+	if info.Uid >= 0 {
+		details.Owner = accounts.Uid(uint32(info.Uid))
+		details.OwnerId = strconv.Itoa(info.Uid)
+	}
+	if info.Pid > 0 {
+		details.Process = getProcessInfo(info.Pid)
+		if details.Owner == "" && details.Process.Uid >= 0 {
+			details.Owner = details.Process.User
+			details.OwnerId = strconv.Itoa(details.Process.Uid)
+		}
+	}
+	return details
+}
@@ -0,0 +1,11 @@
+//go:build !windows && !linux
+
+package main
+
+var osFiles fileSystem = otherFiles{}
+
+// No named pipes, nor a listing of Unix sockets, the pipe modes say so
+var osPipes pipeTransport = libraryPipes{}
+
+// Open tries only, owners and rights are unknown
+type otherFiles struct{}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charlesgargasson/gofspy/pipes"
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+// fakeFiles answers from maps instead of the file system
+type fakeFiles struct {
+	owners   map[string]string
+	ownerIds map[string]string
+	readers  map[string]bool
+	writers  map[string]bool
+	rights   map[string]uint32 // granted masks
+	busy     map[string]uint32 // untested masks
+}
+
+func (f fakeFiles) owner(path string) (string, string) {
+	return f.owners[path], f.ownerIds[path]
+}
+
+func (f fakeFiles) access(path string) (bool, bool) {
+	return f.readers[path], f.writers[path]
+}
+
+func (f fakeFiles) tryRight(path string, kind string, mask uint32) int {
+	switch {
+	case f.busy[path]&mask != 0:
+		return rightUntested
+	case f.rights[path]&mask != 0:
+		return rightGranted
+	}
+	return rightDenied
+}
+
+func (f fakeFiles) canWriteDir(dir string) bool {
+	return f.writers[dir]
+}
+
+// useFiles swaps osFiles for the duration of the test
+func useFiles(t *testing.T, files fileSystem) {
+	saved := osFiles
+	osFiles = files
+	t.Cleanup(func() { osFiles = saved })
+}
+
+// fakeWatcher plays a script of events for each root, then stops like a removed root
+type fakeWatcher struct {
+	scripts map[string][]watch.Event
+}
+
+func (f fakeWatcher) watch(ctx context.Context, root watch.Root, monitortype int) error {
+	script, found := f.scripts[root.Path]
+	if !found {
+		return errors.New("can't open directory")
+	}
+	for _, event := range script {
+		if ctx.Err() != nil {
+			return nil
+		}
+		dispatchEvent(event, monitortype)
+	}
+	return nil
+}
+
+// useWatcher swaps osWatcher for the duration of the test
+func useWatcher(t *testing.T, watcher watcher) {
+	saved := osWatcher
+	osWatcher = watcher
+	t.Cleanup(func() { osWatcher = saved })
+}
+
+// Time of scripted events
+var scriptTime = time.Date(2024, 5, 1, 13, 4, 5, 0, time.UTC)
+
+func scripted(action watch.Action, path string) watch.Event {
+	return watch.Event{Time: scriptTime, Kind: "file", Action: action, Path: path}
+}
+
+// memoryPipes is an in-memory pipeTransport
+// Waiting instances are served oldest first, as Windows does, so squatting works the same
+type memoryPipes struct {
+	mu      sync.Mutex
+	waiting map[string][]*memoryInstance
+	names   map[string]bool
+	dials   map[string]int
+	details map[string]pipeDetails // inspect results
+	denied  map[string]bool        // create fails, as with a server denying new instances
+}
+
+func newMemoryPipes() *memoryPipes {
+	return &memoryPipes{
+		waiting: make(map[string][]*memoryInstance),
+		names:   make(map[string]bool),
+		dials:   make(map[string]int),
+		details: make(map[string]pipeDetails),
+		denied:  make(map[string]bool),
+	}
+}
+
+// usePipes swaps osPipes for the duration of the test, pipe modes run as on Windows
+func usePipes(t *testing.T, transport pipeTransport) {
+	saved, savedSupported, savedListed := osPipes, pipesSupported, pipesListed
+	osPipes, pipesSupported, pipesListed = transport, true, true
+	t.Cleanup(func() { osPipes, pipesSupported, pipesListed = saved, savedSupported, savedListed })
+}
+
+// add needs the lock
+func (p *memoryPipes) add(name string) *memoryInstance {
+	instance := &memoryInstance{pipes: p, name: name, accepted: make(chan net.Conn, 1), closed: make(chan struct{})}
+	p.waiting[name] = append(p.waiting[name], instance)
+	p.names[name] = true
+	return instance
+}
+
+func (p *memoryPipes) remove(instance *memoryInstance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := p.waiting[instance.name]
+	for i, waiting := range queue {
+		if waiting == instance {
+			p.waiting[instance.name] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// connect hands a new connection to the oldest waiting instance of name
+func (p *memoryPipes) connect(name string) (net.Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := p.waiting[name]
+	if len(queue) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	p.waiting[name] = queue[1:]
+	p.dials[name]++
+	client, server := net.Pipe()
+	queue[0].accepted <- server
+	return client, nil
+}
+
+// waitFor returns once count returns at least n
+func (p *memoryPipes) waitFor(t *testing.T, what string, n int, count func() int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p.mu.Lock()
+		got := count()
+		p.mu.Unlock()
+		if got >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d %s, want %d", got, what, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (p *memoryPipes) waitInstances(t *testing.T, name string, n int) {
+	t.Helper()
+	p.waitFor(t, "instances of "+name, n, func() int { return len(p.waiting[name]) })
+}
+
+func (p *memoryPipes) waitDials(t *testing.T, name string, n int) {
+	t.Helper()
+	p.waitFor(t, "dials to "+name, n, func() int { return p.dials[name] })
+}
+
+func (p *memoryPipes) open(name string, access pipes.Access) (pipeClient, error) {
+	conn, err := p.connect(name)
+	if err != nil {
+		return nil, err
+	}
+	return memoryClient{conn}, nil
+}
+
+func (p *memoryPipes) create(name string) (pipeServer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.denied[name] {
+		return nil, fs.ErrPermission
+	}
+	return p.add(name), nil
+}
+
+func (p *memoryPipes) dial(ctx context.Context, name string) (io.ReadWriteCloser, error) {
+	conn, err := p.connect(name)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (p *memoryPipes) listen(name string) (net.Listener, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &memoryListener{pipes: p, name: name, next: p.add(name), done: make(chan struct{})}, nil
+}
+
+func (p *memoryPipes) inspect(name string) pipeDetails {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.details[name]
+}
+
+func (p *memoryPipes) list() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names []string
+	for name := range p.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// memoryInstance is one end of net.Pipe once a client connected
+type memoryInstance struct {
+	pipes     *memoryPipes
+	name      string
+	accepted  chan net.Conn
+	mu        sync.Mutex
+	conn      net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (i *memoryInstance) Connect(ctx context.Context) error {
+	select {
+	case conn := <-i.accepted:
+		i.mu.Lock()
+		i.conn = conn
+		i.mu.Unlock()
+		return nil
+	case <-i.closed:
+		return net.ErrClosed
+	case <-ctx.Done():
+		i.Close()
+		return ctx.Err()
+	}
+}
+
+func (i *memoryInstance) connection() (net.Conn, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.conn == nil {
+		return nil, net.ErrClosed
+	}
+	return i.conn, nil
+}
+
+func (i *memoryInstance) Read(buffer []byte) (int, error) {
+	conn, err := i.connection()
+	if err != nil {
+		return 0, err
+	}
+	return conn.Read(buffer)
+}
+
+func (i *memoryInstance) Write(data []byte) (int, error) {
+	conn, err := i.connection()
+	if err != nil {
+		return 0, err
+	}
+	return conn.Write(data)
+}
+
+func (i *memoryInstance) Close() error {
+	i.closeOnce.Do(func() {
+		close(i.closed)
+		i.pipes.remove(i)
+		if conn, err := i.connection(); err == nil {
+			conn.Close()
+		}
+	})
+	return nil
+}
+
+// memoryListener keeps one instance waiting, as go-winio does
+type memoryListener struct {
+	pipes     *memoryPipes
+	name      string
+	next      *memoryInstance // only used by Accept
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.next.accepted:
+		l.pipes.mu.Lock()
+		l.next = l.pipes.add(l.name)
+		l.pipes.mu.Unlock()
+		return conn, nil
+	case <-l.done:
+		l.next.Close()
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *memoryListener) Addr() net.Addr {
+	return memoryAddr(l.name)
+}
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return "pipe" }
+func (a memoryAddr) String() string  { return string(a) }
+
+// memoryClient reads whole messages like pipes.Client
+type memoryClient struct {
+	conn net.Conn
+}
+
+func (c memoryClient) ReadMessage(ctx context.Context) ([]byte, error) {
+	stopClose := context.AfterFunc(ctx, func() {
+		c.conn.Close()
+	})
+	defer stopClose()
+	_, data, err := readFromConn(c.conn)
+	if ctx.Err() != nil {
+		return data, ctx.Err()
+	}
+	return data, err
+}
+
+func (c memoryClient) WriteMessage(data []byte) error {
+	_, err := c.conn.Write(data)
+	return err
+}
+
+func (c memoryClient) Close() error {
+	return c.conn.Close()
+}
+
+// captureStdout returns what run printed
+func captureStdout(t *testing.T, run func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	defer func() {
+		os.Stdout = saved
+	}()
+	run()
+	writer.Close()
+	return <-output
+}
+
+func TestCheckFileAccess(t *testing.T) {
+	useFiles(t, fakeFiles{
+		readers: map[string]bool{"/r": true, "/rw": true},
+		writers: map[string]bool{"/w": true, "/rw": true},
+	})
+	tests := map[string]string{"/r": "R-", "/w": "-W", "/rw": "RW", "/none": "--"}
+	for path, want := range tests {
+		if _, _, got := checkFileAccess(path); got != want {
+			t.Errorf("checkFileAccess(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestGetFileOwner(t *testing.T) {
+	useFiles(t, fakeFiles{owners: map[string]string{"/etc/passwd": "root"}, ownerIds: map[string]string{"/etc/passwd": "0"}})
+	owner := make(chan fileOwner)
+	go getFileOwner("/etc/passwd", owner)
+	if got := <-owner; got != (fileOwner{name: "root", id: "0"}) {
+		t.Errorf("owner = %+v, want root (0)", got)
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+var osFiles fileSystem = windowsFiles{}
+
+// Named pipes, through the pipes package
+var osPipes pipeTransport = libraryPipes{}
+
+// CreateFile with the rights to test
+type windowsFiles struct{}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charlesgargasson/gofspy/sddl"
+)
+
+// Dump the access control list of files, directories and pipes (-acl)
+var aclDump bool
+
+// One access control entry, decoded
+type aceInfo struct {
+	Trustee string   `json:"trustee"`
+	Sid     string   `json:"sid,omitempty"`
+	Type    string   `json:"type"`            // allow, deny, audit, mask ...
+	Flags   []string `json:"flags,omitempty"` // inheritance: OI, CI, NP, IO, ID (Windows), default (Linux)
+	Mask    uint32   `json:"mask"`
+	Rights  []string `json:"rights"`
+}
+
+// formatAcl returns one line per entry, each starting with prefix
+func formatAcl(acl []aceInfo, prefix string) string {
+	var lines strings.Builder
+	for _, ace := range acl {
+		marker := "⚪"
+		switch ace.Type {
+		case "allow":
+			marker = "✅"
+		case "deny":
+			marker = "⛔"
+		}
+		var flags string
+		if len(ace.Flags) > 0 {
+			flags = fmt.Sprintf(" (%s)", strings.Join(ace.Flags, ","))
+		}
+		rights := strings.Join(ace.Rights, ", ")
+		if rights == "" {
+			rights = "none"
+		}
+		fmt.Fprintf(&lines, "%s%s %-5s %s%s : %s [%#x]\n", prefix, marker, ace.Type, ace.Trustee, flags, rights, ace.Mask)
+	}
+	return lines.String()
+}
+
+// descriptorAcl converts the DACL of a parsed descriptor, kind tells how to name rights (file, dir, pipe)
+func descriptorAcl(sd *sddl.SecurityDescriptor, kind string) []aceInfo {
+	if !sd.HasDacl || sd.NullDacl() {
+		// No DACL at all, anyone has full access
+		return []aceInfo{{Trustee: "Everyone", Type: "allow", Mask: 0x1f01ff, Rights: []string{"full control (null DACL)"}}}
+	}
+	if len(sd.Dacl) == 0 {
+		return []aceInfo{{Trustee: "Everyone", Type: "deny", Rights: []string{"no access (empty DACL)"}}}
+	}
+	return convertAces(sd.Dacl, kind)
+}
+
+func convertAces(aces []sddl.ACE, kind string) []aceInfo {
+	var acl []aceInfo
+	for _, ace := range aces {
+		acl = append(acl, aceInfo{
+			Trustee: accounts.label(sidName(ace.SID), string(ace.SID)),
+			Sid:     string(ace.SID),
+			Type:    ace.TypeName(),
+			Flags:   sddl.FlagNames(ace.Flags),
+			Mask:    ace.Mask,
+			Rights:  ace.Rights(kind),
+		})
+	}
+	return acl
+}
+
+// printSddl decodes an SDDL string captured elsewhere (-sddl), rights are named as for files
+func printSddl(value string) {
+	sd, err := sddl.Parse(value)
+	if err != nil {
+		fmt.Printf("[*] SDDL error: %v\n", err)
+		return
+	}
+
+	if jsonOutput {
+		data, _ := json.Marshal(struct {
+			*sddl.SecurityDescriptor
+			OwnerName string    `json:"owner_name,omitempty"`
+			GroupName string    `json:"group_name,omitempty"`
+			Acl       []aceInfo `json:"acl"`
+			Audit     []aceInfo `json:"audit,omitempty"`
+		}{sd, sidName(sd.Owner), sidName(sd.Group), descriptorAcl(sd, "file"), convertAces(sd.Sacl, "file")})
+		os.Stdout.Write(append(data, '\n'))
+		return
+	}
+
+	fmt.Printf("[*] %s\n", sd.String())
+	if sd.Owner != "" {
+		fmt.Printf("    Owner : %s\n", sidWithName(sd.Owner))
+	}
+	if sd.Group != "" {
+		fmt.Printf("    Group : %s\n", sidWithName(sd.Group))
+	}
+	fmt.Println(strings.TrimRight("    DACL "+sd.DaclFlags, " "))
+	fmt.Print(formatAcl(descriptorAcl(sd, "file"), "      "))
+	if sd.HasSacl {
+		fmt.Println(strings.TrimRight("    SACL "+sd.SaclFlags, " "))
+		fmt.Print(formatAcl(convertAces(sd.Sacl, "file"), "      "))
+	}
+}
+
+// sidWithName returns "name (SID)", once, whatever -showids and the lookup result
+func sidWithName(sid sddl.SID) string {
+	name := sidName(sid)
+	if strings.Contains(name, string(sid)) {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, sid)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/binary"
+	"os/user"
+	"strconv"
+
+	"github.com/charlesgargasson/gofspy/sddl"
+	"golang.org/x/sys/unix"
+)
+
+// POSIX ACL extended attributes, see acl(5)
+const (
+	aclXattrAccess  = "system.posix_acl_access"
+	aclXattrDefault = "system.posix_acl_default"
+	aclXattrVersion = 2
+
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+var posixRightNames = map[string][]string{
+	"file": {"read", "write", "execute"},
+	"dir":  {"list", "add/remove entries", "traverse"},
+}
+
+// posixRights decodes rwx bits, in that order
+func posixRights(perm uint32, kind string) []string {
+	names, found := posixRightNames[kind]
+	if !found {
+		names = posixRightNames["file"]
+	}
+	var rights []string
+	for index, bit := range []uint32{4, 2, 1} {
+		if perm&bit != 0 {
+			rights = append(rights, names[index])
+		}
+	}
+	return rights
+}
+
+// sidName returns the well-known name of a Windows SID, or the SID itself (-sddl)
+func sidName(sid sddl.SID) string {
+	return accounts.Resolve("sid:"+string(sid), string(sid), func() (string, error) {
+		return sid.Name(), nil
+	})
+}
+
+// lookupGid returns the group name, or the raw gid when unknown
+func lookupGid(gid uint32) string {
+	raw := strconv.FormatUint(uint64(gid), 10)
+	return accounts.Resolve("gid:"+raw, raw, func() (string, error) {
+		group, err := user.LookupGroupId(raw)
+		if err != nil {
+			return "", err
+		}
+		return group.Name, nil
+	})
+}
+
+func posixAce(tag uint16, id uint32, perm uint32, kind string, stat *unix.Stat_t) aceInfo {
+	ace := aceInfo{Type: "allow", Mask: perm}
+	switch tag {
+	case aclUserObj:
+		ace.Trustee = "owner " + accounts.label(accounts.Uid(stat.Uid), strconv.FormatUint(uint64(stat.Uid), 10))
+	case aclUser:
+		ace.Trustee = "user " + accounts.label(accounts.Uid(id), strconv.FormatUint(uint64(id), 10))
+	case aclGroupObj:
+		ace.Trustee = "owning group " + accounts.label(lookupGid(stat.Gid), strconv.FormatUint(uint64(stat.Gid), 10))
+	case aclGroup:
+		ace.Trustee = "group " + accounts.label(lookupGid(id), strconv.FormatUint(uint64(id), 10))
+	case aclMask:
+		// Upper bound for named users and all groups
+		ace.Type = "mask"
+		ace.Trustee = "mask"
+	case aclOther:
+		ace.Trustee = "other"
+	}
+	ace.Rights = posixRights(perm, kind)
+	return ace
+}
+
+// readPosixAcl decodes an ACL extended attribute, nil when there is none
+func readPosixAcl(path string, attribute string, kind string, stat *unix.Stat_t) []aceInfo {
+	buffer := make([]byte, 4096)
+	size, err := unix.Getxattr(path, attribute, buffer)
+	if err != nil || size < 4 || binary.LittleEndian.Uint32(buffer) != aclXattrVersion {
+		return nil
+	}
+
+	var acl []aceInfo
+	for offset := 4; offset+8 <= size; offset += 8 {
+		tag := binary.LittleEndian.Uint16(buffer[offset:])
+		perm := binary.LittleEndian.Uint16(buffer[offset+2:])
+		id := binary.LittleEndian.Uint32(buffer[offset+4:])
+		acl = append(acl, posixAce(tag, id, uint32(perm), kind, stat))
+	}
+	return acl
+}
+
+// getFileAcl returns the POSIX ACL, or the mode bits as owner, group and other entries
+// Default entries of directories are inherited by new entries
+func getFileAcl(path string, kind string) ([]aceInfo, error) {
+	var stat unix.Stat_t
+	err := unix.Stat(path, &stat)
+	if err != nil {
+		return nil, err
+	}
+
+	acl := readPosixAcl(path, aclXattrAccess, kind, &stat)
+	if acl == nil {
+		acl = []aceInfo{
+			posixAce(aclUserObj, 0, (stat.Mode>>6)&7, kind, &stat),
+			posixAce(aclGroupObj, 0, (stat.Mode>>3)&7, kind, &stat),
+			posixAce(aclOther, 0, stat.Mode&7, kind, &stat),
+		}
+	}
+
+	if kind == "dir" {
+		for _, ace := range readPosixAcl(path, aclXattrDefault, kind, &stat) {
+			ace.Flags = []string{"default"}
+			acl = append(acl, ace)
+		}
+	}
+	return acl, nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/charlesgargasson/gofspy/sddl"
+)
+
+var errAclUnsupported = errors.New("ACLs are only read on Linux and Windows")
+
+// sidName returns the well-known name of a Windows SID, or the SID itself (-sddl)
+func sidName(sid sddl.SID) string {
+	if name := sid.Name(); name != "" {
+		return name
+	}
+	return string(sid)
+}
+
+func getFileAcl(path string, kind string) ([]aceInfo, error) {
+	return nil, errAclUnsupported
+}
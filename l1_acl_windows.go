@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/charlesgargasson/gofspy/sddl"
+	"golang.org/x/sys/windows"
+)
+
+// sidName returns "DOMAIN\user" as the system names it, then the well-known name, then the SID
+func sidName(sid sddl.SID) string {
+	return accounts.Sid(string(sid))
+}
+
+// decodeDescriptor goes through SDDL, the same decoding as -sddl
+func decodeDescriptor(sd *windows.SECURITY_DESCRIPTOR, kind string) ([]aceInfo, error) {
+	parsed, err := sddl.Parse(sd.String())
+	if err != nil {
+		return nil, err
+	}
+	return descriptorAcl(parsed, kind), nil
+}
+
+// getHandleAcl needs READ_CONTROL on the handle
+func getHandleAcl(handle windows.Handle, kind string) ([]aceInfo, error) {
+	sd, err := windows.GetSecurityInfo(handle, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDescriptor(sd, kind)
+}
+
+func getFileAcl(path string, kind string) ([]aceInfo, error) {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDescriptor(sd, kind)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// Copies of new files into an evidence directory (-capture), nil when disabled
+var capturer *captureConfig
+
+// Scripts and binaries, when no -capturematch is given
+var defaultCapturePatterns = []string{
+	"*.ps1", "*.psm1", "*.bat", "*.cmd", "*.vbs", "*.js", "*.hta",
+	"*.exe", "*.dll", "*.sys", "*.msi",
+	"*.sh", "*.py", "*.pl", "*.so",
+}
+
+// Files copied at once, other requests wait for a worker
+const captureWorkers = 2
+
+// Copies are taken once writes settle, like hashes
+type captureConfig struct {
+	*settlePool[*evidenceCopy]
+	dir     string
+	match   []*regexp.Regexp
+	maxSize int64
+}
+
+// Metadata written next to each copy
+type captureSidecar struct {
+	Path      string    `json:"path"`
+	Action    string    `json:"action"`
+	EventTime time.Time `json:"event_time"`
+	Captured  time.Time `json:"captured"`
+	Size      int64     `json:"size"`
+	Sha256    string    `json:"sha256"`
+	Owner     string    `json:"owner"`
+	OwnerId   string    `json:"owner_id,omitempty"`
+	Process   *procInfo `json:"process,omitempty"`
+}
+
+func newCaptureConfig(dir string, patterns []string, maxSize int64) (*captureConfig, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(absDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		patterns = defaultCapturePatterns
+	}
+	match, err := compilePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Our own copies must not be reported, nor captured again
+	excludeTree(absDir)
+
+	config := &captureConfig{dir: absDir, match: match, maxSize: maxSize}
+	config.settlePool = newSettlePool(captureWorkers, config.evidence)
+	return config, nil
+}
+
+// excludeTree hides dir and everything below from monitoring
+func excludeTree(dir string) {
+	expr := "^" + regexp.QuoteMeta(filepath.Clean(dir)) + `($|[\\/])`
+	if runtime.GOOS == "windows" {
+		expr = "(?i)" + expr
+	}
+	if filter == nil {
+		filter = &eventFilter{}
+	}
+	filter.exclude = append(filter.exclude, regexp.MustCompile(expr))
+}
+
+func (c *captureConfig) matches(path string, action uint32) bool {
+	return c != nil && shouldHash(action) && matchAny(c.match, path)
+}
+
+// capture copies path into the evidence directory, returns the copy path, its size and sha256
+func (c *captureConfig) capture(path string) (string, int64, string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", 0, "", err
+	}
+	if info.IsDir() {
+		return "", 0, "", errors.New("directory")
+	}
+	if info.Size() > c.maxSize {
+		return "", 0, "", fmt.Errorf("%d bytes, above -capturemax", info.Size())
+	}
+
+	// Timestamped name, never overwrite a previous copy
+	var evidence string
+	var destination *os.File
+	stamp := time.Now().Format("20060102T150405.000000")
+	for i := 0; destination == nil; i++ {
+		name := fmt.Sprintf("%s_%s", stamp, filepath.Base(path))
+		if i > 0 {
+			name = fmt.Sprintf("%s_%d_%s", stamp, i, filepath.Base(path))
+		}
+		evidence = filepath.Join(c.dir, name)
+		destination, err = os.OpenFile(evidence, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil && !os.IsExist(err) {
+			return "", 0, "", err
+		}
+	}
+	defer destination.Close()
+
+	sha := sha256.New()
+	size, err := io.Copy(io.MultiWriter(destination, sha), io.LimitReader(source, c.maxSize))
+	if err != nil {
+		return evidence, size, "", err
+	}
+	return evidence, size, hex.EncodeToString(sha.Sum(nil)), nil
+}
+
+// evidenceCopy is a copy in the evidence directory, its sidecar is written once the event is enriched
+type evidenceCopy struct {
+	path string
+	size int64
+	sha  string
+}
+
+// evidence copies path, nil when it couldn't
+func (c *captureConfig) evidence(path string) *evidenceCopy {
+	copyPath, size, sha, err := c.capture(path)
+	if err != nil && debug {
+		printNotice("[DEBUG] capture %s: %v\n", path, err)
+	}
+	if copyPath == "" {
+		return nil
+	}
+	return &evidenceCopy{path: copyPath, size: size, sha: sha}
+}
+
+// requestCapture returns a channel receiving the copy of the file of event, nil when it doesn't match -capturematch
+// Events rejected by the filters are never copied
+func requestCapture(event fsEvent) chan *evidenceCopy {
+	if event.Kind != "file" || !capturer.matches(event.Path, event.actionCode) || !filter.allowEvent(event) {
+		return nil
+	}
+	return capturer.request(event.Path)
+}
+
+// record adds the copy to event and writes its sidecar
+func (e *evidenceCopy) record(event *fsEvent) {
+	if e == nil {
+		return
+	}
+	event.Capture = e.path
+	capturer.writeSidecar(e.path, e.size, e.sha, *event)
+}
+
+func (c *captureConfig) writeSidecar(evidence string, size int64, sha string, event fsEvent) {
+	sidecar := captureSidecar{
+		Path:      event.Path,
+		Action:    event.Action,
+		EventTime: event.Time,
+		Captured:  time.Now(),
+		Size:      size,
+		Sha256:    sha,
+		Owner:     event.Owner,
+		OwnerId:   event.OwnerId,
+		Process:   event.Process,
+	}
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(evidence+".json", data, 0600)
+	if err != nil && debug {
+		printNotice("[DEBUG] capture sidecar %s: %v\n", evidence, err)
+	}
+}
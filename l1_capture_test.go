@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureWhenEnrichmentDropped(t *testing.T) {
+	evidenceDir := t.TempDir()
+	config, err := newCaptureConfig(evidenceDir, []string{"*.ps1"}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedCapturer, savedEnricher, savedFilter := capturer, enricher, filter
+	defer func() { capturer, enricher, filter = savedCapturer, savedEnricher, savedFilter }()
+	capturer = config
+
+	// The only worker is busy and nothing can wait, every event is dropped
+	pool, err := newEnrichPool(1, 0, "drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy := make(chan struct{})
+	for started := false; !started; {
+		started = true
+		pool.submit(func() { <-busy }, func() { started = false })
+	}
+	enricher = pool
+
+	script := filepath.Join(t.TempDir(), "drop.ps1")
+	if err := os.WriteFile(script, []byte("whoami"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	output := captureStdout(t, func() {
+		handleFileEvent(newFileEvent(script, FILE_ACTION_ADDED, scriptTime), 0)
+		close(busy)
+		pool.wait()
+	})
+	if !strings.Contains(output, "⏭️") || !strings.Contains(output, "📥 "+evidenceDir) {
+		t.Errorf("output %q", output)
+	}
+
+	copies, _ := filepath.Glob(filepath.Join(evidenceDir, "*_drop.ps1"))
+	if len(copies) != 1 {
+		t.Fatalf("copies %v", copies)
+	}
+	if data, _ := os.ReadFile(copies[0]); string(data) != "whoami" {
+		t.Errorf("copy holds %q", data)
+	}
+	if _, err := os.Stat(copies[0] + ".json"); err != nil {
+		t.Errorf("no sidecar (%v)", err)
+	}
+}
+
+func TestCaptureSettledAndFiltered(t *testing.T) {
+	evidenceDir := t.TempDir()
+	config, err := newCaptureConfig(evidenceDir, []string{"*.ps1"}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedCapturer, savedEnricher, savedFilter := capturer, enricher, filter
+	defer func() { capturer, enricher, filter = savedCapturer, savedEnricher, savedFilter }()
+	capturer = config
+	enricher, err = newEnrichPool(4, 16, "block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err = newEventFilter(nil, nil, []string{"alice"}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.ps1")
+	skipped := filepath.Join(dir, "skipped.ps1")
+	useFiles(t, fakeFiles{owners: map[string]string{kept: "alice", skipped: "bob"}})
+
+	// Written in chunks, a single copy of the final content
+	output := captureStdout(t, func() {
+		var content []byte
+		for _, part := range []string{"who", "am", "i"} {
+			content = append(content, part...)
+			os.WriteFile(kept, content, 0o600)
+			os.WriteFile(skipped, content, 0o600)
+			handleFileEvent(newFileEvent(kept, FILE_ACTION_MODIFIED, scriptTime), 0)
+			handleFileEvent(newFileEvent(skipped, FILE_ACTION_MODIFIED, scriptTime), 0)
+		}
+		enricher.wait()
+	})
+
+	copies, _ := filepath.Glob(filepath.Join(evidenceDir, "*_kept.ps1"))
+	if len(copies) != 1 {
+		t.Fatalf("copies %v", copies)
+	}
+	if data, _ := os.ReadFile(copies[0]); string(data) != "whoami" {
+		t.Errorf("copy holds %q", data)
+	}
+	if strings.Count(output, "📥 "+copies[0]) != 3 {
+		t.Errorf("output %q", output)
+	}
+
+	// Filtered out by -owner, nothing on disk
+	if others, _ := filepath.Glob(filepath.Join(evidenceDir, "*_skipped.ps1*")); len(others) > 0 {
+		t.Errorf("filtered event captured %v", others)
+	}
+}
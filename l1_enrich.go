@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Enrichment of events (access, owner, ACL, hashes ...) runs in a bounded pool
+// nil runs the work in the caller
+var enricher *enrichPool
+
+var overloadPolicies = []string{"block", "drop"}
+
+// Bounded pool of enrichment workers
+// When the queue is full, "block" waits for room (slowing the watcher down, which may lead to overflows)
+// and "drop" reports the event without enrichment
+type enrichPool struct {
+	queue   chan func()
+	drop    bool
+	pending sync.WaitGroup
+
+	done    atomic.Uint64
+	dropped atomic.Uint64
+	blocked atomic.Uint64 // submissions that had to wait for room
+}
+
+func newEnrichPool(workers int, queueSize int, policy string) (*enrichPool, error) {
+	if !slices.Contains(overloadPolicies, policy) {
+		return nil, fmt.Errorf("unknown overload policy %q (valid: %s)", policy, strings.Join(overloadPolicies, ","))
+	}
+
+	pool := &enrichPool{
+		queue: make(chan func(), max(queueSize, 0)),
+		drop:  policy == "drop",
+	}
+	for range max(workers, 1) {
+		go pool.worker()
+	}
+	return pool, nil
+}
+
+// submit queues work, fallback runs in the caller instead when the queue is full and the policy is drop
+func (pool *enrichPool) submit(work func(), fallback func()) {
+	if pool == nil {
+		work()
+		return
+	}
+
+	pool.pending.Add(1)
+	select {
+	case pool.queue <- work:
+		return
+	default:
+	}
+
+	if pool.drop {
+		pool.pending.Done()
+		pool.dropped.Add(1)
+		fallback()
+		return
+	}
+	pool.blocked.Add(1)
+	pool.queue <- work
+}
+
+func (pool *enrichPool) worker() {
+	for work := range pool.queue {
+		work()
+		pool.done.Add(1)
+		pool.pending.Done()
+	}
+}
+
+// detach runs work that waits on another bounded pool (hashes) without holding a worker
+// It is still pending, so wait covers it and the event is printed before exit
+func (pool *enrichPool) detach(work func()) {
+	if pool == nil {
+		work()
+		return
+	}
+	pool.pending.Add(1)
+	go func() {
+		defer pool.pending.Done()
+		work()
+		pool.done.Add(1)
+	}()
+}
+
+// wait returns once submitted work is done
+func (pool *enrichPool) wait() {
+	if pool != nil {
+		pool.pending.Wait()
+	}
+}
+
+// summary returns the counters, for -stats
+func (pool *enrichPool) summary() string {
+	if pool == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d done, %d queued, ⏭️ %d dropped, %d blocked", pool.done.Load(), len(pool.queue), pool.dropped.Load(), pool.blocked.Load())
+}
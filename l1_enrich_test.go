@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnrichPoolWaitsForDetached(t *testing.T) {
+	pool, err := newEnrichPool(1, 1, "block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	var reported atomic.Bool
+	pool.submit(func() {
+		// A hashed event, waiting for its hash elsewhere
+		pool.detach(func() {
+			<-release
+			reported.Store(true)
+		})
+	}, nil)
+
+	waited := make(chan struct{})
+	go func() {
+		pool.wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("wait returned before the detached work")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The worker is free meanwhile
+	ran := make(chan struct{})
+	pool.submit(func() { close(ran) }, nil)
+	<-ran
+
+	close(release)
+	<-waited
+	if !reported.Load() {
+		t.Error("detached work didn't finish")
+	}
+}
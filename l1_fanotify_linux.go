@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/charlesgargasson/gofspy/watch"
+	"golang.org/x/sys/unix"
+)
+
+const fanotifyMask = unix.FAN_OPEN | unix.FAN_MODIFY | unix.FAN_CLOSE_WRITE
+
+const fanotifyMetadataSize = int(unsafe.Sizeof(unix.FanotifyEventMetadata{}))
+
+// getProcessInfo reads executable and uid of a process from /proc
+func getProcessInfo(pid int) *procInfo {
+	process := &procInfo{Pid: pid, Uid: -1}
+	process.Exe, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return process
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		// Uid: real effective saved fs
+		if len(fields) > 1 && fields[0] == "Uid:" {
+			uid, err := strconv.ParseUint(fields[1], 10, 32)
+			if err == nil {
+				process.Uid = int(uid)
+				process.User = accounts.Uid(uint32(uid))
+			}
+			break
+		}
+	}
+	return process
+}
+
+func underRoots(path string, roots []watch.Root) bool {
+	for _, root := range roots {
+		if root.Allows(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// monitorfanotify watches the mounts holding the given roots and reports the process behind each event
+// Needs CAP_SYS_ADMIN
+func monitorfanotify(ctx context.Context, roots []watch.Root, monitortype int) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		printNotice("[*] Error starting fanotify, root is required (%v)\n", err)
+		return
+	}
+	// Closing the file ends a pending read, as for inotify
+	file := os.NewFile(uintptr(fd), "fanotify")
+	defer file.Close()
+	stopClose := context.AfterFunc(ctx, func() {
+		file.Close()
+	})
+	defer stopClose()
+
+	var markedRoots []watch.Root
+	for _, root := range roots {
+		err = unix.FanotifyMark(fd, unix.FAN_MARK_ADD|unix.FAN_MARK_MOUNT, fanotifyMask, unix.AT_FDCWD, root.Path)
+		if err != nil {
+			printNotice("[*] Error watching %s with fanotify (%v)\n", root.Path, err)
+			continue
+		}
+		markedRoots = append(markedRoots, root)
+	}
+	if len(markedRoots) == 0 {
+		return
+	}
+
+	// fanotify has no create or delete event, rescans find the ones lost with an overflow
+	var trees []*watch.Tree
+	if rescanOnOverflow && monitortype == 0 {
+		for _, root := range markedRoots {
+			trees = append(trees, watch.NewTree(root))
+		}
+	}
+
+	// Our own enrichment opens files too
+	selfPid := os.Getpid()
+
+	buffer := make([]byte, 64*1024)
+	for {
+		bytesReturned, err := file.Read(buffer)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			printNotice("Failed to monitor with fanotify: %v\n", err)
+			break
+		}
+
+		currentTime := time.Now()
+		offset := 0
+		for offset+fanotifyMetadataSize <= bytesReturned {
+			record := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buffer[offset]))
+			if record.Vers != unix.FANOTIFY_METADATA_VERSION || int(record.Event_len) < fanotifyMetadataSize {
+				printNotice("Unexpected fanotify metadata version\n")
+				return
+			}
+			offset += int(record.Event_len)
+
+			if record.Mask&unix.FAN_Q_OVERFLOW != 0 {
+				fanotifyOverflow(markedRoots, trees, monitortype, currentTime)
+				continue
+			}
+			if record.Fd == unix.FAN_NOFD {
+				continue
+			}
+
+			path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", record.Fd))
+			unix.Close(int(record.Fd))
+			if err != nil || int(record.Pid) == selfPid || !underRoots(path, markedRoots) {
+				continue
+			}
+
+			for i, tree := range trees {
+				if underRoots(path, markedRoots[i:i+1]) {
+					tree.Seen(path)
+				}
+			}
+
+			var action uint32
+			switch {
+			case record.Mask&unix.FAN_CLOSE_WRITE != 0:
+				action = FILE_ACTION_CLOSED_WRITE
+			case record.Mask&unix.FAN_MODIFY != 0:
+				action = FILE_ACTION_MODIFIED
+			case record.Mask&unix.FAN_OPEN != 0:
+				action = FILE_ACTION_OPENED
+			default:
+				continue
+			}
+
+			if !filter.allowPath(path, action, monitortype) {
+				continue
+			}
+
+			// Read /proc now, the process may be gone soon
+			process := getProcessInfo(int(record.Pid))
+			handleFileWithProcess(path, action, monitortype, currentTime, process)
+		}
+	}
+}
+
+// fanotifyOverflow reports the overflow on every root as inotify does, and rescans them with -rescan
+func fanotifyOverflow(roots []watch.Root, trees []*watch.Tree, monitortype int, givenTime time.Time) {
+	for _, root := range roots {
+		dispatchEvent(watch.Event{Time: givenTime, Root: root.Path, Kind: "dir", Action: watch.Overflow, Path: root.Path}, monitortype)
+	}
+	for _, tree := range trees {
+		go func() {
+			events, _ := tree.Rescan()
+			for _, event := range events {
+				dispatchEvent(event, monitortype)
+			}
+		}()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+func monitorfanotify(ctx context.Context, roots []watch.Root, monitortype int) {
+	printNotice("[*] fanotify is only available on Linux\n")
+}
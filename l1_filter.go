@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Repeatable string flag (-include, -exclude, -owner ...)
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Filter applied between the watchers and handleFile, nil means no filter
+type eventFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	owners  []*regexp.Regexp
+	actions map[string]bool
+	kinds   map[string]bool
+	access  map[string]bool
+}
+
+var filter *eventFilter
+
+var filterActions = []string{"added", "removed", "modified", "renamed", "moved_out", "moved_in", "existing", "opened", "closed_write", "overflow"}
+var filterKinds = []string{"file", "dir", "pipe"}
+
+// compilePattern turns a glob (* and ?) or a "re:" prefixed regex into a regexp
+// Globs match the whole path, case insensitive on Windows
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, found := strings.CutPrefix(pattern, "re:"); found {
+		return regexp.Compile(expr)
+	}
+
+	var expr strings.Builder
+	if runtime.GOOS == "windows" {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q (%v)", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// parseChoices reads a comma separated list and checks each value against allowed ones
+func parseChoices(list string, allowed []string, name string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	choices := make(map[string]bool)
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if allowed != nil && !slices.Contains(allowed, value) {
+			return nil, fmt.Errorf("unknown %s %q (valid: %s)", name, value, strings.Join(allowed, ","))
+		}
+		choices[value] = true
+	}
+	return choices, nil
+}
+
+func newEventFilter(include []string, exclude []string, owners []string, actions string, kinds string, access string) (*eventFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 && len(owners) == 0 && actions == "" && kinds == "" && access == "" {
+		return nil, nil
+	}
+
+	var err error
+	f := &eventFilter{}
+	if f.include, err = compilePatterns(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, err
+	}
+	if f.owners, err = compilePatterns(owners); err != nil {
+		return nil, err
+	}
+	if f.actions, err = parseChoices(actions, filterActions, "action"); err != nil {
+		return nil, err
+	}
+	if f.kinds, err = parseChoices(kinds, filterKinds, "kind"); err != nil {
+		return nil, err
+	}
+	if f.access, err = parseChoices(strings.ToUpper(access), nil, "access"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowPath is checked before enrichment, using only what the watcher knows
+func (f *eventFilter) allowPath(path string, action uint32, monitortype int) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.actions != nil && !f.actions[getActionName(action)] {
+		return false
+	}
+
+	if f.kinds != nil {
+		if monitortype == 1 || monitortype == 2 {
+			if !f.kinds["pipe"] {
+				return false
+			}
+		} else if !f.kinds["file"] && !f.kinds["dir"] {
+			return false
+		}
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include, path) {
+		return false
+	}
+	return !matchAny(f.exclude, path)
+}
+
+// allowEvent is checked after enrichment, on kind, owner and access
+func (f *eventFilter) allowEvent(event fsEvent) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.kinds != nil && !f.kinds[event.Kind] {
+		return false
+	}
+
+	if len(f.owners) > 0 {
+		if event.Owner == "" {
+			return false
+		}
+		// Match "DOMAIN\user" or only "user"
+		user := event.Owner[strings.LastIndex(event.Owner, `\`)+1:]
+		if !matchAny(f.owners, event.Owner) && !matchAny(f.owners, user) {
+			return false
+		}
+	}
+
+	if f.access != nil && !f.access[event.Access] {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOwnerFilterWithIds(t *testing.T) {
+	accounts.both = true
+	defer func() { accounts.both = false }()
+
+	filter, err := newEventFilter(nil, nil, []string{"SYSTEM"}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := newFileEvent(`C:\Windows\Temp\a.dll`, FILE_ACTION_ADDED, time.Date(2024, 5, 1, 13, 4, 5, 0, time.UTC))
+	event.Owner, event.OwnerId = `NT AUTHORITY\SYSTEM`, "S-1-5-18"
+	if !filter.allowEvent(event) {
+		t.Error("owner filter dropped the event when ids are shown")
+	}
+
+	// The id is only added for display
+	got := captureStdout(t, func() { printFileEvent(event, 0) })
+	if !strings.Contains(got, `[NT AUTHORITY\SYSTEM (S-1-5-18)]`) {
+		t.Errorf("line %q", got)
+	}
+
+	event.Owner, event.OwnerId = `BUILTIN\Users`, "S-1-5-32-545"
+	if filter.allowEvent(event) {
+		t.Error("owner filter let another owner through")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Content hashing of added and modified files (-hash), nil when disabled
+var hasher *hashPool
+
+var hashAlgorithms = []string{"sha256", "md5", "sha1"}
+
+// Bounded pool of hashing workers, files are hashed once their writes settle
+type hashPool struct {
+	*settlePool[map[string]string]
+	algorithms []string
+	maxSize    int64
+}
+
+func newHashPool(algorithms string, maxSize int64, workers int) (*hashPool, error) {
+	pool := &hashPool{maxSize: maxSize}
+
+	choices, err := parseChoices(strings.ToLower(algorithms), hashAlgorithms, "hash")
+	if err != nil {
+		return nil, err
+	}
+	// Keep a stable order
+	for _, algorithm := range hashAlgorithms {
+		if choices[algorithm] {
+			pool.algorithms = append(pool.algorithms, algorithm)
+		}
+	}
+
+	pool.settlePool = newSettlePool(workers, pool.hash)
+	return pool, nil
+}
+
+// hash returns the hashes of path, nil when skipped
+func (pool *hashPool) hash(path string) map[string]string {
+	hashes, err := pool.hashFile(path)
+	if err != nil && debug {
+		printNotice("[DEBUG] hash %s: %v\n", path, err)
+	}
+	return hashes
+}
+
+func (pool *hashPool) hashFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+	if info.Size() > pool.maxSize {
+		return nil, fmt.Errorf("%d bytes, above -hashmax", info.Size())
+	}
+
+	hashers := make(map[string]hash.Hash)
+	var writers []io.Writer
+	for _, algorithm := range pool.algorithms {
+		switch algorithm {
+		case "sha256":
+			hashers[algorithm] = sha256.New()
+		case "md5":
+			hashers[algorithm] = md5.New()
+		case "sha1":
+			hashers[algorithm] = sha1.New()
+		}
+		writers = append(writers, hashers[algorithm])
+	}
+
+	// The file may grow meanwhile, never read more than the cap
+	_, err = io.Copy(io.MultiWriter(writers...), io.LimitReader(file, pool.maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]string)
+	for algorithm, h := range hashers {
+		hashes[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes, nil
+}
+
+// shouldHash tells which actions bring new content
+func shouldHash(action uint32) bool {
+	switch action {
+	case FILE_ACTION_ADDED, FILE_ACTION_MODIFIED, FILE_ACTION_CLOSED_WRITE, FILE_ACTION_RENAMED, FILE_ACTION_MOVED_IN:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashDebounce(t *testing.T) {
+	pool, err := newHashPool("sha256", 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "growing")
+
+	// Written in three parts, the last one after the first window would have ended
+	var results []chan map[string]string
+	var content []byte
+	for _, part := range []string{"one ", "two ", "three"} {
+		content = append(content, part...)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, pool.request(path))
+		time.Sleep(settleDebounce * 6 / 10)
+	}
+
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:])
+	for i, result := range results {
+		if got := (<-result)["sha256"]; got != want {
+			t.Errorf("request %d got %s, want the hash of the final content %s", i, got, want)
+		}
+	}
+}
@@ -1,35 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
-	"unsafe"
 
-	"golang.org/x/sys/windows"
+	"github.com/charlesgargasson/gofspy/watch"
 )
 
+// Same values as the watch package actions
 const (
-	FILE_NOTIFY_CHANGE_FILE_NAME   = 0x00000001
-	FILE_NOTIFY_CHANGE_DIR_NAME    = 0x00000002
-	FILE_NOTIFY_CHANGE_ATTRIBUTES  = 0x00000004
-	FILE_NOTIFY_CHANGE_SIZE        = 0x00000008
-	FILE_NOTIFY_CHANGE_LAST_WRITE  = 0x00000010
-	FILE_NOTIFY_CHANGE_LAST_ACCESS = 0x00000020
-	FILE_NOTIFY_CHANGE_CREATION    = 0x00000040
-	FILE_NOTIFY_CHANGE_SECURITY    = 0x00000100
-)
-
-const (
-	FILE_ACTION_ADDED            = 0x00000001
-	FILE_ACTION_REMOVED          = 0x00000002
-	FILE_ACTION_MODIFIED         = 0x00000003
-	FILE_ACTION_RENAMED_OLD_NAME = 0x00000004
-	FILE_ACTION_RENAMED_NEW_NAME = 0x00000005
-	FILE_ACTION_STARTING_GOFSPY  = 0x10101010
+	FILE_ACTION_ADDED            = uint32(watch.Added)
+	FILE_ACTION_REMOVED          = uint32(watch.Removed)
+	FILE_ACTION_MODIFIED         = uint32(watch.Modified)
+	FILE_ACTION_RENAMED_OLD_NAME = uint32(watch.RenamedOldName)
+	FILE_ACTION_RENAMED_NEW_NAME = uint32(watch.RenamedNewName)
+	FILE_ACTION_STARTING_GOFSPY  = uint32(watch.Existing)
+	FILE_ACTION_OPENED           = uint32(watch.Opened)
+	FILE_ACTION_CLOSED_WRITE     = uint32(watch.ClosedWrite)
+	FILE_ACTION_RENAMED          = uint32(watch.Renamed)  // old and new names paired
+	FILE_ACTION_MOVED_OUT        = uint32(watch.MovedOut) // old name without new name
+	FILE_ACTION_MOVED_IN         = uint32(watch.MovedIn)  // new name without old name
+	FILE_ACTION_OVERFLOW         = uint32(watch.Overflow) // watcher lost events
 )
 
 func getActionType(action uint32, monitortype int) (string, bool) {
@@ -52,10 +48,10 @@ func getActionType(action uint32, monitortype int) (string, bool) {
 		}
 		return "🟠", true
 
-	case FILE_ACTION_RENAMED_OLD_NAME:
+	case FILE_ACTION_RENAMED_OLD_NAME, FILE_ACTION_MOVED_OUT:
 		return "🟣", false
 
-	case FILE_ACTION_RENAMED_NEW_NAME:
+	case FILE_ACTION_RENAMED_NEW_NAME, FILE_ACTION_MOVED_IN, FILE_ACTION_RENAMED:
 		if monitortype == 1 || monitortype == 2 {
 			return "🔵", false
 		}
@@ -67,178 +63,263 @@ func getActionType(action uint32, monitortype int) (string, bool) {
 		}
 		return "⚪", true
 
+	case FILE_ACTION_OPENED:
+		return "🟡", true
+
+	case FILE_ACTION_CLOSED_WRITE:
+		return "🟤", true
+
+	case FILE_ACTION_OVERFLOW:
+		return "⚠️", false
+
 	default:
 		return "?", false
 	}
 }
 
-func handleFile(path string, action uint32, monitortype int, givenTime time.Time) {
-	var owner string
-	var displayAccess string
-	var hijackable string
-	actiontype, testAccess := getActionType(action, monitortype)
+func getActionName(action uint32) string {
+	return watch.Action(action).String()
+}
+
+func printFileEvent(event fsEvent, monitortype int) {
+	actiontype, _ := getActionType(event.actionCode, monitortype)
 	emoji := "📁"
-	if monitortype == 1 || monitortype == 2 {
+	if event.Kind == "pipe" {
 		emoji = "💧"
 	}
 
-	if monitortype == 1 || monitortype == 2 {
-		path = strings.Replace(path, `\\.\pipe\\`, `\\.\pipe\`, -1)
-	}
-
-	// Named pipes Hijack
-	if hijack > 0 && (monitortype == 1 || monitortype == 2) && (action == FILE_ACTION_ADDED || action == FILE_ACTION_STARTING_GOFSPY) {
-		// Check if Hijackable
-		hijackHandle, err := createDuplexPipe(path)
-		if err == nil {
-			if hijack == 2 {
-				windows.CloseHandle(hijackHandle)
-				defer startServerHJ(path)
-			} else {
-				go windows.CloseHandle(hijackHandle)
-			}
-			hijackable = "🔥 "
-		}
+	displayAccess := fmt.Sprintf("%-2s", event.Access)
+	if event.Kind == "dir" {
+		displayAccess = "📁"
 	}
 
-	if !testAccess {
-		fmt.Printf("%s %s %-2s %s %s%s\n", emoji, timeFormat(givenTime), displayAccess, actiontype, hijackable, path)
-		return
+	var hijackable string
+	if event.Hijackable {
+		hijackable = "🔥 "
+	}
+	if event.Rescan {
+		hijackable += "♻️ "
+	}
+	if event.Unenriched {
+		hijackable += "⏭️ "
 	}
 
-	// Named pipes
-	if monitortype == 1 || monitortype == 2 {
-		// Get access list and valid handle
-		handle, _, _, _, controlAccess, displayAccess := bestFileHandle(path)
-		defer func() {
-			go windows.CloseHandle(handle)
-		}()
-
-		// Get owner
-		if controlAccess {
-			var wg sync.WaitGroup
-			wg.Add(1)
-			var owner string
-			go getHandleOwner(handle, &owner, &wg)
-			wg.Wait()
-		}
-		fmt.Printf("%s %s %-2s %s %s%s%s\n", emoji, timeFormat(givenTime), displayAccess, actiontype, hijackable, owner, path)
-		return
+	var owner string
+	if event.Owner != "" {
+		owner = fmt.Sprintf("[%s] ", accounts.label(event.Owner, event.OwnerId))
 	}
 
-	// Start to search owner
-	owner_ch := make(chan string)
-	go getFileOwner(path, owner_ch)
+	var details string
+	if len(event.Risks) > 0 {
+		details = " 🚩 " + strings.Join(event.Risks, ", ")
+	}
+	if event.Process != nil {
+		user := event.Process.User
+		if user == "" {
+			user = "?"
+		} else if event.Process.Uid >= 0 {
+			user = accounts.label(user, strconv.Itoa(event.Process.Uid))
+		}
+		details += fmt.Sprintf(" ⬅ [%d:%s] %s", event.Process.Pid, user, event.Process.Exe)
+	}
 
-	// Retrieve RW acess infos
-	fileAttr, err := os.Stat(path)
-	if err == nil {
-		if !fileAttr.IsDir() {
-			_, _, displayAccess = checkFileAccess(path)
-		} else {
-			displayAccess = "📁"
+	path := event.Path
+	if event.OldPath != "" {
+		path = fmt.Sprintf("%s → %s", event.OldPath, event.Path)
+	}
+	for _, algorithm := range hashAlgorithms {
+		if value, found := event.Hashes[algorithm]; found {
+			details += fmt.Sprintf(" %s:%s", algorithm, value)
 		}
-		owner = <-owner_ch
-		fmt.Printf("%s %s %s %s %s%s%s\n", emoji, timeFormat(givenTime), displayAccess, actiontype, hijackable, owner, path)
+	}
+	if event.Capture != "" {
+		details += " 📥 " + event.Capture
+	}
+	if len(event.Changes) > 0 {
+		details += fmt.Sprintf(" (%s)", strings.Join(event.Changes, ", "))
+	}
+
+	// Granted rights, then one line per ACE below the event
+	below := formatProbe(event.Probe, "      ") + formatAcl(event.Acl, "      ")
+
+	printEvent(event, "%s %s %s %s %s%s%s%s\n%s", emoji, timeFormat(event.Time), displayAccess, actiontype, hijackable, owner, path, details, below)
+}
+
+// Roots watched by monitorpath, followDrives skips them
+var watching = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+func isWatching(path string) bool {
+	watching.Lock()
+	defer watching.Unlock()
+	return watching.paths[path]
+}
+
+// monitorpath reports the changes below root until ctx is cancelled, or the root is gone
+func monitorpath(ctx context.Context, root watch.Root, monitortype int) {
+	watching.Lock()
+	if watching.paths[root.Path] {
+		watching.Unlock()
 		return
 	}
+	watching.paths[root.Path] = true
+	watching.Unlock()
+	defer func() {
+		watching.Lock()
+		delete(watching.paths, root.Path)
+		watching.Unlock()
+	}()
+
+	if err := osWatcher.watch(ctx, root, monitortype); err != nil {
+		printNotice("Error watching %s: %v\n", root.Path, err)
+	}
+}
 
-	owner = <-owner_ch
-	fmt.Printf("%s %s %-2s %s %s%s%s\n", emoji, timeFormat(givenTime), displayAccess, actiontype, hijackable, owner, path)
+// reportFileEvent applies post-enrichment filters and prints the event
+func reportFileEvent(event fsEvent, monitortype int) {
+	if !filter.allowEvent(event) {
+		return
+	}
+	stats.record(event)
+	printFileEvent(event, monitortype)
 }
 
-func monitorpath(path string, monitortype int) {
-	dirHandle, err := syscall.CreateFile(
-		syscall.StringToUTF16Ptr(path),
-		syscall.FILE_LIST_DIRECTORY,
-		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
-		nil,
-		syscall.OPEN_EXISTING,
-		syscall.FILE_FLAG_BACKUP_SEMANTICS,
-		0,
-	)
-
-	defer syscall.CloseHandle(dirHandle)
-
-	if err != nil {
-		fmt.Println("Error opening directory:", err)
+// dispatchEvent applies path filters to an event of the watch package and handles it
+func dispatchEvent(change watch.Event, monitortype int) {
+	action := uint32(change.Action)
+
+	// Overflows are about the root, not a path to filter
+	if change.Action == watch.Overflow {
+		event := newFileEvent(change.Path, action, change.Time)
+		event.Kind = "dir"
+		reportFileEvent(event, monitortype)
 		return
 	}
 
-	for {
-		buffer := make([]byte, 4096)
-		var bytesReturned uint32
-		ret, _, _ := procReadDirectoryChangesW.Call(
-			uintptr(dirHandle),
-			uintptr(unsafe.Pointer(&buffer[0])),
-			uintptr(len(buffer)),
-			1, // Watch subtree
-			FILE_NOTIFY_CHANGE_FILE_NAME|FILE_NOTIFY_CHANGE_DIR_NAME|
-				FILE_NOTIFY_CHANGE_ATTRIBUTES|FILE_NOTIFY_CHANGE_SIZE|
-				FILE_NOTIFY_CHANGE_LAST_WRITE|FILE_NOTIFY_CHANGE_CREATION,
-			uintptr(unsafe.Pointer(&bytesReturned)),
-			0,
-			0,
-		)
-		if ret == 0 {
-			fmt.Println("Failed to monitor directory")
-			break
-		}
+	if !filter.allowPath(change.Path, action, monitortype) &&
+		(change.OldPath == "" || !filter.allowPath(change.OldPath, action, monitortype)) {
+		return
+	}
 
-		go func() {
-			currentTime := time.Now()
-			offset := 0
-			for offset < int(bytesReturned) {
-				record := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buffer[offset]))
-				action := record.Action
-				fileName := utf16ToString(&record.FileName, record.FileNameLength)
-				fullname := path + fileName
-				go handleFile(fullname, action, monitortype, currentTime)
-
-				if record.NextEntryOffset == 0 {
-					break
-				}
-				offset += int(record.NextEntryOffset)
-			}
-		}()
+	event := newFileEvent(change.Path, action, change.Time)
+	event.OldPath = change.OldPath
+	event.Rescan = change.Rescan
+	handleFileEvent(event, monitortype)
+}
+
+func newFileEvent(path string, action uint32, givenTime time.Time) fsEvent {
+	return fsEvent{
+		Time:       givenTime,
+		Kind:       "file",
+		Action:     getActionName(action),
+		Path:       path,
+		actionCode: action,
 	}
 }
 
-func monitornamedpipes(checkAccess bool, quitAfterList bool) {
-	// Specify the folder path
-	path := `\\.\pipe\`
+func handleFile(path string, action uint32, monitortype int, givenTime time.Time) {
+	handleFileEvent(newFileEvent(path, action, givenTime), monitortype)
+}
+
+// handleFileWithProcess is handleFile for watchers that know which process caused the event
+func handleFileWithProcess(path string, action uint32, monitortype int, givenTime time.Time, process *procInfo) {
+	event := newFileEvent(path, action, givenTime)
+	event.Process = process
+	handleFileEvent(event, monitortype)
+}
+
+// handleFileEvent enriches the event in the pool, on the new name for renames
+// Events are still reported when the pool drops their enrichment
+func handleFileEvent(event fsEvent, monitortype int) {
+	_, testAccess := getActionType(event.actionCode, monitortype)
 
-	// Read the directory contents
-	files, err := os.ReadDir(path)
-	if err != nil {
-		fmt.Printf("Error reading directory: %v\n", err)
+	// Named pipes, hijack checks go through the pool as well
+	if monitortype == 1 || monitortype == 2 {
+		enricher.submit(func() {
+			handlePipe(event, monitortype, testAccess)
+		}, func() {
+			event = namePipeEvent(event)
+			event.Unenriched = true
+			reportFileEvent(event, monitortype)
+		})
 		return
 	}
 
-	currentTime := time.Now()
-	var action uint32
-	action = FILE_ACTION_STARTING_GOFSPY
-	monitortype := 1
-	if checkAccess {
-		monitortype = 2
+	if !testAccess {
+		finishFileEvent(event, monitortype, nil, requestCapture(event))
+		return
 	}
 
-	var wg sync.WaitGroup
+	// Dropped events are still captured, when they pass the filters without enrichment
+	enricher.submit(func() {
+		enrichFileEvent(event, monitortype)
+	}, func() {
+		event.Unenriched = true
+		finishFileEvent(event, monitortype, nil, requestCapture(event))
+	})
+}
 
-	// Print each named pipe
-	for _, file := range files {
-		fullname := path + file.Name()
-		wg.Add(1)
-		go func() {
-			handleFile(fullname, action, monitortype, currentTime)
-			defer wg.Done()
-		}()
+// namePipeEvent fixes kind and path of events from the pipe watcher
+func namePipeEvent(event fsEvent) fsEvent {
+	event.Kind = "pipe"
+	event.Path = strings.Replace(event.Path, `\\.\pipe\\`, `\\.\pipe\`, -1)
+	return event
+}
+
+// enrichFileEvent adds access, owner and the optional details (hashes, ACL ...) then reports the event
+func enrichFileEvent(event fsEvent, monitortype int) {
+	path := event.Path
+
+	// Start to search owner
+	owner_ch := make(chan fileOwner)
+	go getFileOwner(path, owner_ch)
+
+	// Retrieve RW acess infos
+	var hash_ch chan map[string]string
+	fileAttr, err := os.Stat(path)
+	if err == nil {
+		if !fileAttr.IsDir() {
+			if hasher != nil && shouldHash(event.actionCode) {
+				hash_ch = hasher.request(path)
+			}
+			_, _, event.Access = checkFileAccess(path)
+		} else {
+			event.Kind = "dir"
+		}
 	}
 
-	if !quitAfterList {
-		go monitorpath(path, 1)
-		select {}
+	owner := <-owner_ch
+	event.Owner, event.OwnerId = owner.name, owner.id
+	if err == nil {
+		event.Risks = privescReasons(event, fileAttr)
+		if accessProbe {
+			event.Probe = probeAccess(path, event.Kind)
+		}
+		if aclDump {
+			event.Acl, _ = getFileAcl(path, event.Kind)
+		}
 	}
 
-	wg.Wait()
+	finishFileEvent(event, monitortype, hash_ch, requestCapture(event))
+}
+
+// finishFileEvent reports the event once its hashes and -capture copy are ready, nil channels are skipped
+// Both wait for writes to settle in their pool, don't hold a worker meanwhile
+func finishFileEvent(event fsEvent, monitortype int, hash_ch chan map[string]string, capture_ch chan *evidenceCopy) {
+	finish := func() {
+		if hash_ch != nil {
+			event.Hashes = <-hash_ch
+		}
+		if capture_ch != nil {
+			(<-capture_ch).record(&event)
+		}
+		reportFileEvent(event, monitortype)
+	}
+	if hash_ch != nil || capture_ch != nil {
+		enricher.detach(finish)
+	} else {
+		finish()
+	}
 }
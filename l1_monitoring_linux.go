@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Default roots, the usual places for privesc on Linux
+func defaultRoots() []string {
+	var roots []string
+	for _, root := range []string{"/etc", "/home", "/root", "/tmp", "/opt", "/srv", "/usr/local", "/var/tmp", "/var/spool", "/var/www", "/dev/shm"} {
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// Unix sockets and FIFOs have no watcher, they are listed again every pipePollInterval
+func watchPipes(ctx context.Context, listed []string) {
+	pollPipes(ctx, listed, 1)
+}
+
+// Default roots are directories, they don't come and go like drives
+func followDrives(ctx context.Context, monitortype int, interval time.Duration) {}
@@ -0,0 +1,26 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Default roots, the home and temporary directories
+func defaultRoots() []string {
+	var roots []string
+	home, _ := os.UserHomeDir()
+	for _, root := range []string{home, os.TempDir()} {
+		if info, err := os.Stat(root); root != "" && err == nil && info.IsDir() {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// Pipes aren't listed here, monitornamedpipes stops before
+func watchPipes(ctx context.Context, listed []string) {}
+
+func followDrives(ctx context.Context, monitortype int, interval time.Duration) {}
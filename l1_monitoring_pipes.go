@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+// Named pipes are reported by handleFile with monitortype 1 (list) or 2 (list and check)
+func handlePipe(event fsEvent, monitortype int, testAccess bool) {
+	event = namePipeEvent(event)
+	path := event.Path
+
+	// Named pipes Hijack
+	if hijack > 0 && (event.actionCode == FILE_ACTION_ADDED || event.actionCode == FILE_ACTION_STARTING_GOFSPY) {
+		// Check if Hijackable
+		server, err := osPipes.create(path)
+		if err == nil {
+			server.Close()
+			if hijack == 2 {
+				// The server keeps running, don't hold a pool worker
+				defer func() {
+					go startServerHJ(appContext, path)
+				}()
+			}
+			event.Hijackable = true
+		}
+	}
+
+	if !testAccess {
+		reportFileEvent(event, monitortype)
+		return
+	}
+
+	// Before inspect, each try takes a pipe instance
+	if accessProbe {
+		event.Probe = probeAccess(path, "pipe")
+	}
+
+	details := osPipes.inspect(path)
+	event.Access = details.Access
+	event.Owner = details.Owner
+	event.OwnerId = details.OwnerId
+	event.Acl = details.Acl
+	event.Process = details.Process
+	reportFileEvent(event, monitortype)
+}
+
+func monitornamedpipes(ctx context.Context, checkAccess bool, quitAfterList bool) {
+	if !pipesListed {
+		printNotice("[*] %v\n", errPipesUnsupported)
+		return
+	}
+
+	names, err := osPipes.list()
+	if err != nil {
+		printNotice("Error reading directory: %v\n", err)
+		return
+	}
+
+	currentTime := time.Now()
+	var action uint32
+	action = FILE_ACTION_STARTING_GOFSPY
+	monitortype := 1
+	if checkAccess {
+		monitortype = 2
+	}
+
+	// Print each named pipe
+	for _, fullname := range names {
+		if !filter.allowPath(fullname, action, monitortype) {
+			continue
+		}
+		handleFile(fullname, action, monitortype, currentTime)
+	}
+
+	if !quitAfterList {
+		watchPipes(ctx, names)
+		return
+	}
+
+	enricher.wait()
+}
+
+// Linux has no event for sockets bound or gone, pollPipes lists them again after this
+var pipePollInterval = 2 * time.Second
+
+// pollPipes reports the pipes added and removed since the previous list, known is the first one
+func pollPipes(ctx context.Context, known []string, monitortype int) {
+	ticker := time.NewTicker(pipePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		names, err := osPipes.list()
+		if err != nil {
+			printNotice("[*] Can't list pipes (%v)\n", err)
+			continue
+		}
+		currentTime := time.Now()
+
+		current := make(map[string]bool, len(names))
+		for _, name := range names {
+			current[name] = true
+		}
+		previous := make(map[string]bool, len(known))
+		for _, name := range known {
+			previous[name] = true
+			if !current[name] {
+				dispatchEvent(watch.Event{Time: currentTime, Action: watch.Removed, Path: name}, monitortype)
+			}
+		}
+		for _, name := range names {
+			if !previous[name] {
+				dispatchEvent(watch.Event{Time: currentTime, Action: watch.Added, Path: name}, monitortype)
+			}
+		}
+		known = names
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlePipeHijack(t *testing.T) {
+	memory := newMemoryPipes()
+	memory.details[`\\.\pipe\open`] = pipeDetails{Read: true, Write: true, Control: true, Access: "RW", Owner: `NT AUTHORITY\SYSTEM`}
+	memory.details[`\\.\pipe\owned`] = pipeDetails{Access: "--"}
+	memory.denied[`\\.\pipe\owned`] = true
+	usePipes(t, memory)
+	hijack = 1
+	defer func() { hijack = 0 }()
+
+	output := captureStdout(t, func() {
+		for _, name := range []string{`\\.\pipe\open`, `\\.\pipe\owned`} {
+			handlePipe(newFileEvent(name, FILE_ACTION_STARTING_GOFSPY, scriptTime), 2, true)
+		}
+	})
+	want := "💧 13:04:05 RW ⚪ 🔥 [NT AUTHORITY\\SYSTEM] \\\\.\\pipe\\open\n" +
+		"💧 13:04:05 -- ⚪ \\\\.\\pipe\\owned\n"
+	if output != want {
+		t.Errorf("got\n%s\nwant\n%s", output, want)
+	}
+
+	// The hijack check doesn't leave our instance behind
+	if waiting := len(memory.waiting[`\\.\pipe\open`]); waiting != 0 {
+		t.Errorf("%d instances left", waiting)
+	}
+}
+
+func TestMonitorNamedPipesList(t *testing.T) {
+	memory := newMemoryPipes()
+	memory.names[`\\.\pipe\a`] = true
+	memory.names[`\\.\pipe\b`] = true
+	usePipes(t, memory)
+
+	output := captureStdout(t, func() {
+		monitornamedpipes(context.Background(), false, true)
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ` \\.\pipe\a`) || !strings.HasSuffix(lines[1], ` \\.\pipe\b`) {
+		t.Errorf("output:\n%s", output)
+	}
+}
+
+func TestMonitorNamedPipesUnsupported(t *testing.T) {
+	saved := pipesListed
+	pipesListed = false
+	defer func() { pipesListed = saved }()
+
+	output := captureStdout(t, func() {
+		monitornamedpipes(context.Background(), false, true)
+	})
+	if output != "[*] "+errPipesUnsupported.Error()+"\n" {
+		t.Errorf("output %q", output)
+	}
+}
+
+func TestPollPipes(t *testing.T) {
+	memory := newMemoryPipes()
+	memory.names["/run/a.sock"] = true
+	memory.names["@c"] = true
+	usePipes(t, memory)
+	saved := pipePollInterval
+	pipePollInterval = 5 * time.Millisecond
+	defer func() { pipePollInterval = saved }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := captureStdout(t, func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pollPipes(ctx, []string{"/run/a.sock", "/run/b.fifo"}, 1)
+		}()
+		// Later lists are the same, nothing more is reported
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "❌ /run/b.fifo") || !strings.Contains(lines[1], "🟢 @c") {
+		t.Errorf("output:\n%s", output)
+	}
+}
+
+func TestCheckPipeProcess(t *testing.T) {
+	memory := newMemoryPipes()
+	memory.details["/run/app.sock"] = pipeDetails{Read: true, Write: true, Control: true, Access: "RW", Pid: 42, Owner: "root",
+		Process: &procInfo{Pid: 42, Exe: "/usr/sbin/appd", User: "root"}}
+	usePipes(t, memory)
+
+	output := captureStdout(t, func() {
+		checkPipe(context.Background(), "/run/app.sock")
+	})
+	for _, want := range []string{"⚪ Pid: 42", "⚪ Process: /usr/sbin/appd", "⚪ Owner: [root]", "🟢 Readable", "🟢 Writable"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("missing %q in\n%s", want, output)
+		}
+	}
+
+	// Monitoring shows the holder after the path
+	output = captureStdout(t, func() {
+		handlePipe(newFileEvent("/run/app.sock", FILE_ACTION_STARTING_GOFSPY, scriptTime), 2, true)
+	})
+	if want := "💧 13:04:05 RW ⚪ [root] /run/app.sock ⬅ [42:root] /usr/sbin/appd\n"; output != want {
+		t.Errorf("got %q, want %q", output, want)
+	}
+}
+
+func TestCheckPipeJson(t *testing.T) {
+	memory := newMemoryPipes()
+	memory.details["/run/app.sock"] = pipeDetails{Read: true, Write: true, Control: true, Access: "RW", Pid: 42, Owner: "root", OwnerId: "0",
+		Process: &procInfo{Pid: 42, Exe: "/usr/sbin/appd", User: "root"}}
+	usePipes(t, memory)
+	jsonOutput, hijack = true, 1
+	defer func() { jsonOutput, hijack = false, 0 }()
+
+	// One event, no text line
+	output := captureStdout(t, func() {
+		checkPipe(context.Background(), "/run/app.sock")
+	})
+	var decoded fsEvent
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatalf("%v: %q", err, output)
+	}
+	if decoded.Action != "check" || decoded.Access != "RW" || decoded.OwnerId != "0" || !decoded.Hijackable ||
+		decoded.Process == nil || decoded.Process.Exe != "/usr/sbin/appd" {
+		t.Errorf("decoded %+v", decoded)
+	}
+}
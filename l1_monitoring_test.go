@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+func TestGetActionType(t *testing.T) {
+	tests := []struct {
+		action      uint32
+		monitortype int
+		emoji       string
+		enrich      bool
+	}{
+		{FILE_ACTION_ADDED, 0, "🟢", true},
+		{FILE_ACTION_ADDED, 1, "🟢", false},
+		{FILE_ACTION_ADDED, 2, "🟢", false},
+		{FILE_ACTION_REMOVED, 0, "❌", false},
+		{FILE_ACTION_MODIFIED, 0, "🟠", true},
+		{FILE_ACTION_MODIFIED, 2, "🟠", false},
+		{FILE_ACTION_RENAMED_OLD_NAME, 0, "🟣", false},
+		{FILE_ACTION_MOVED_OUT, 0, "🟣", false},
+		{FILE_ACTION_RENAMED, 0, "🔵", true},
+		{FILE_ACTION_MOVED_IN, 1, "🔵", false},
+		{FILE_ACTION_STARTING_GOFSPY, 1, "⚪", false},
+		{FILE_ACTION_STARTING_GOFSPY, 2, "⚪", true},
+		{FILE_ACTION_OPENED, 0, "🟡", true},
+		{FILE_ACTION_CLOSED_WRITE, 0, "🟤", true},
+		{FILE_ACTION_OVERFLOW, 0, "⚠️", false},
+		{0x42, 0, "?", false},
+	}
+	for _, test := range tests {
+		emoji, enrich := getActionType(test.action, test.monitortype)
+		if emoji != test.emoji || enrich != test.enrich {
+			t.Errorf("getActionType(%s, %d) = %s %v, want %s %v", getActionName(test.action), test.monitortype, emoji, enrich, test.emoji, test.enrich)
+		}
+	}
+}
+
+func TestPrintFileEvent(t *testing.T) {
+	givenTime := time.Date(2024, 5, 1, 13, 4, 5, 0, time.UTC)
+	event := newFileEvent("/tmp/run.sh", FILE_ACTION_RENAMED, givenTime)
+	event.OldPath = "/tmp/run.tmp"
+	event.Access = "RW"
+	event.Owner = "root"
+	event.Hijackable = true
+	event.Risks = []string{"writable, owned by root"}
+
+	got := captureStdout(t, func() { printFileEvent(event, 0) })
+	want := "📁 13:04:05 RW 🔵 🔥 [root] /tmp/run.tmp → /tmp/run.sh 🚩 writable, owned by root\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	pipe := newFileEvent(`\\.\pipe\spoolss`, FILE_ACTION_STARTING_GOFSPY, givenTime)
+	pipe.Kind = "pipe"
+	got = captureStdout(t, func() { printFileEvent(pipe, 1) })
+	if !strings.HasPrefix(got, "💧 13:04:05    ⚪ ") {
+		t.Errorf("pipe line %q", got)
+	}
+}
+
+func TestPrintFileEventJson(t *testing.T) {
+	jsonOutput = true
+	defer func() { jsonOutput = false }()
+
+	event := newFileEvent("/tmp/a", FILE_ACTION_ADDED, time.Now())
+	got := captureStdout(t, func() { printFileEvent(event, 0) })
+
+	var decoded fsEvent
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("%v: %q", err, got)
+	}
+	if decoded.Action != "added" || decoded.Path != "/tmp/a" || decoded.Kind != "file" {
+		t.Errorf("decoded %+v", decoded)
+	}
+}
+
+func TestMonitorErrorJson(t *testing.T) {
+	useWatcher(t, fakeWatcher{})
+	jsonOutput = true
+	defer func() { jsonOutput = false }()
+
+	// The error goes to stderr, stdout only holds events
+	got := captureStdout(t, func() {
+		monitorpath(context.Background(), watch.Root{Path: "/missing"}, 0)
+	})
+	if got != "" {
+		t.Errorf("stdout %q", got)
+	}
+}
+
+func TestScriptedMonitoring(t *testing.T) {
+	useFiles(t, fakeFiles{owners: map[string]string{"/srv/a": "alice", "/srv/b": "bob"}})
+	useWatcher(t, fakeWatcher{scripts: map[string][]watch.Event{"/srv": {
+		scripted(watch.Added, "/srv/a"),
+		scripted(watch.Modified, "/srv/a"),
+		{Time: scriptTime, Kind: "file", Action: watch.Renamed, Path: "/srv/b", OldPath: "/srv/a"},
+		scripted(watch.MovedOut, "/srv/c"),
+		scripted(watch.MovedIn, "/srv/d"),
+		scripted(watch.Removed, "/srv/b"),
+		{Time: scriptTime, Kind: "dir", Action: watch.Overflow, Path: "/srv"},
+	}}})
+
+	output := captureStdout(t, func() {
+		monitorpath(context.Background(), watch.Root{Path: "/srv", Recursive: true}, 0)
+	})
+	want := []string{
+		"📁 13:04:05    🟢 [alice] /srv/a",
+		"📁 13:04:05    🟠 [alice] /srv/a",
+		"📁 13:04:05    🔵 [bob] /srv/a → /srv/b",
+		"📁 13:04:05    🟣 /srv/c",
+		"📁 13:04:05    🔵 /srv/d",
+		"📁 13:04:05    ❌ /srv/b",
+		"📁 13:04:05 📁 ⚠️ /srv",
+	}
+	if got := strings.Split(strings.TrimSpace(output), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestScriptedRenameFilter(t *testing.T) {
+	useFiles(t, fakeFiles{})
+	useWatcher(t, fakeWatcher{scripts: map[string][]watch.Event{"/srv": {
+		scripted(watch.Added, "/srv/tmp/x"),
+		// Renames out of the excluded directory are kept, on either name
+		{Time: scriptTime, Kind: "file", Action: watch.Renamed, Path: "/srv/run.sh", OldPath: "/srv/tmp/x"},
+		{Time: scriptTime, Kind: "file", Action: watch.Renamed, Path: "/srv/tmp/y", OldPath: "/srv/run.sh"},
+		{Time: scriptTime, Kind: "file", Action: watch.Renamed, Path: "/srv/tmp/z", OldPath: "/srv/tmp/y"},
+	}}})
+	var err error
+	filter, err = newEventFilter(nil, []string{"/srv/tmp/*"}, nil, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { filter = nil }()
+
+	output := captureStdout(t, func() {
+		monitorpath(context.Background(), watch.Root{Path: "/srv", Recursive: true}, 0)
+	})
+	if strings.Count(output, "\n") != 2 || !strings.Contains(output, "/srv/tmp/x → /srv/run.sh") || !strings.Contains(output, "/srv/run.sh → /srv/tmp/y") {
+		t.Errorf("output:\n%s", output)
+	}
+}
+
+func TestMonitorPathError(t *testing.T) {
+	useWatcher(t, fakeWatcher{})
+	output := captureStdout(t, func() {
+		monitorpath(context.Background(), watch.Root{Path: "/missing"}, 0)
+	})
+	if output != "Error watching /missing: can't open directory\n" {
+		t.Errorf("output %q", output)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charlesgargasson/gofspy/pipes"
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+// The pipe directory is watched like a drive
+func watchPipes(ctx context.Context, listed []string) {
+	monitorpath(ctx, watch.Root{Path: pipes.Root, Recursive: true}, 1)
+}
+
+// Default roots are all existing drives
+func defaultRoots() []string {
+	var roots []string
+	for driveLetter := 'C'; driveLetter <= 'Z'; driveLetter++ {
+		drivePath := fmt.Sprintf("%c:\\", driveLetter)
+		if _, err := os.Stat(drivePath); err == nil {
+			roots = append(roots, drivePath)
+		}
+	}
+	return roots
+}
+
+// followDrives watches drives plugged in after start (default roots only)
+// The watch of a removed drive stops on its own
+func followDrives(ctx context.Context, monitortype int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	known := make(map[string]bool)
+	for _, drive := range defaultRoots() {
+		known[drive] = true
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		drives := make(map[string]bool)
+		for _, drive := range defaultRoots() {
+			drives[drive] = true
+			if isWatching(drive) {
+				continue
+			}
+			printNotice("[*] Watching new drive %s\n", drive)
+			go monitorpath(ctx, watch.Root{Path: drive, Recursive: true}, monitortype)
+		}
+		for drive := range known {
+			if !drives[drive] {
+				printNotice("[*] Drive %s removed\n", drive)
+			}
+		}
+		known = drives
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+// Account names of SIDs (Windows) and uids / gids (Linux), shared by every lookup and the watchers
+var accounts = &nameCache{Names: watch.NewNames(time.Second)}
+
+type nameCache struct {
+	*watch.Names
+	both bool // label prints "name (id)" (-showids)
+}
+
+// label is name for display, followed by its id with -showids
+// Names are kept apart from ids everywhere else, filters match names only
+func (cache *nameCache) label(name string, id string) string {
+	if !cache.both || id == "" || name == id {
+		return name
+	}
+	return name + " (" + id + ")"
+}
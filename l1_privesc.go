@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// privescReasons flags risky combinations of owner, access and location, from the current user point of view
+// The parent directory is only probed when a check needs it
+func privescReasons(event fsEvent, info fs.FileInfo) []string {
+	// Everything is writable for a privileged user, nothing to learn
+	if info == nil || info.IsDir() || runningPrivileged() {
+		return nil
+	}
+
+	var reasons []string
+	privileged := isPrivilegedOwner(event)
+	if privileged && strings.Contains(event.Access, "W") {
+		reasons = append(reasons, fmt.Sprintf("writable, owned by %s", event.Owner))
+	}
+
+	// New content, including a chmod +x after the write
+	newContent := shouldHash(event.actionCode)
+	newExecutable := newContent && isExecutable(event.Path, info)
+	privilegedWrite := newContent && privileged
+	if !newExecutable && !privilegedWrite {
+		return reasons
+	}
+	if !osFiles.canWriteDir(filepath.Dir(event.Path)) {
+		return reasons
+	}
+
+	if newExecutable {
+		reasons = append(reasons, "executable written in a writable directory")
+	}
+	if privilegedWrite {
+		reasons = append(reasons, fmt.Sprintf("owned by %s, written in a writable directory", event.Owner))
+	}
+	return reasons
+}
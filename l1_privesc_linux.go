@@ -0,0 +1,26 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+var executableExtensions = []string{".so", ".sh", ".py", ".pl"}
+
+func runningPrivileged() bool {
+	return os.Geteuid() == 0
+}
+
+func isPrivilegedOwner(event fsEvent) bool {
+	return event.OwnerId == "0"
+}
+
+// isExecutable checks the exec bits, then the usual script and library extensions
+func isExecutable(path string, info fs.FileInfo) bool {
+	if info.Mode()&0111 != 0 {
+		return true
+	}
+	return slices.Contains(executableExtensions, filepath.Ext(path))
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+func runningPrivileged() bool {
+	return os.Geteuid() == 0
+}
+
+// Owners are unknown here
+func isPrivilegedOwner(event fsEvent) bool {
+	return false
+}
+
+func isExecutable(path string, info fs.FileInfo) bool {
+	return info.Mode()&0111 != 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+var executableExtensions = []string{
+	".exe", ".dll", ".sys", ".com", ".scr", ".cpl", ".ocx", ".msi",
+	".ps1", ".psm1", ".bat", ".cmd", ".vbs", ".js", ".hta",
+}
+
+// TrustedInstaller has no well-known SID type
+const trustedInstallerSid = "S-1-5-80-956008885-3418522649-1831038044-1853292631-2271478464"
+
+// SIDs of privileged accounts, names are localized and their lookup may time out
+var privilegedOwners = sync.OnceValue(func() map[string]bool {
+	sids := []string{trustedInstallerSid}
+	for _, sidType := range []windows.WELL_KNOWN_SID_TYPE{
+		windows.WinLocalSystemSid,
+		windows.WinBuiltinAdministratorsSid,
+		windows.WinLocalServiceSid,
+		windows.WinNetworkServiceSid,
+	} {
+		sid, err := windows.CreateWellKnownSid(sidType)
+		if err == nil {
+			sids = append(sids, sid.String())
+		}
+	}
+
+	owners := make(map[string]bool)
+	for _, sid := range sids {
+		owners[strings.ToUpper(sid)] = true
+	}
+	return owners
+})
+
+// Elevated tokens bypass most DACLs (backup and restore privileges)
+var runningPrivileged = sync.OnceValue(func() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+})
+
+// isPrivilegedOwner matches the owner SID, whatever its name resolved to
+func isPrivilegedOwner(event fsEvent) bool {
+	return event.OwnerId != "" && privilegedOwners()[strings.ToUpper(event.OwnerId)]
+}
+
+func isExecutable(path string, info fs.FileInfo) bool {
+	return slices.Contains(executableExtensions, strings.ToLower(filepath.Ext(path)))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Try each specific right on its own (-probe)
+var accessProbe bool
+
+// accessRight is one right tried by -probe, critical ones lead to privilege escalation
+type accessRight struct {
+	mask     uint32
+	name     string
+	critical bool
+}
+
+// Result of a single try
+const (
+	rightDenied = iota
+	rightGranted
+	rightUntested // busy pipe, sharing violation ...
+)
+
+// probeResult holds the granted rights
+type probeResult struct {
+	Granted  uint32   `json:"granted"`
+	Rights   []string `json:"rights,omitempty"`
+	Critical []string `json:"critical,omitempty"`
+	Untested []string `json:"untested,omitempty"`
+}
+
+// probeAccess tries every right of kind (file, dir, pipe) one after the other
+// Pipes are tried sequentially on purpose, each open takes a pipe instance
+func probeAccess(path string, kind string) *probeResult {
+	result := &probeResult{}
+	for _, right := range probeRights(kind) {
+		switch osFiles.tryRight(path, kind, right.mask) {
+		case rightGranted:
+			result.Granted |= right.mask
+			result.Rights = append(result.Rights, right.name)
+			if right.critical {
+				result.Critical = append(result.Critical, right.name)
+			}
+		case rightUntested:
+			result.Untested = append(result.Untested, right.name)
+		}
+	}
+	return result
+}
+
+// formatProbe returns the granted rights on one line, critical ones flagged with 🚩
+func formatProbe(result *probeResult, prefix string) string {
+	if result == nil {
+		return ""
+	}
+	var rights []string
+	for _, name := range result.Rights {
+		for _, critical := range result.Critical {
+			if name == critical {
+				name = "🚩 " + name
+				break
+			}
+		}
+		rights = append(rights, name)
+	}
+	granted := strings.Join(rights, ", ")
+	if granted == "" {
+		granted = "none"
+	}
+	line := fmt.Sprintf("%s🔑 %s [%#x]", prefix, granted, result.Granted)
+	if len(result.Untested) > 0 {
+		line += fmt.Sprintf(" (untested: %s)", strings.Join(result.Untested, ", "))
+	}
+	return line + "\n"
+}
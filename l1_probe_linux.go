@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// Rights tried by -probe, the low bits follow the mode bits (r=4, w=2, x=1)
+const (
+	probeExecute = 0x1
+	probeWrite   = 0x2
+	probeRead    = 0x4
+	probeChmod   = 0x8
+	probeChown   = 0x10
+	probeDelete  = 0x20
+)
+
+func probeRights(kind string) []accessRight {
+	if kind == "dir" {
+		return []accessRight{
+			{probeRead, "list", false},
+			{probeWrite, "add/remove entries", true},
+			{probeExecute, "traverse", false},
+			{probeChmod, "chmod", true},
+			{probeChown, "chown", true},
+			{probeDelete, "delete", false},
+		}
+	}
+	return []accessRight{
+		{probeRead, "read", false},
+		{probeWrite, "write", true},
+		{probeExecute, "execute", false},
+		{probeChmod, "chmod", true},
+		{probeChown, "chown", true},
+		{probeDelete, "delete", false},
+	}
+}
+
+// tryRight uses access(2) with the effective ids, nothing is opened
+// so probing doesn't trigger events on watched trees
+func (linuxFiles) tryRight(path string, kind string, mask uint32) int {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return rightUntested
+	}
+	euid := uint32(unix.Geteuid())
+
+	granted := false
+	switch mask {
+	case probeRead:
+		granted = eaccess(path, unix.R_OK)
+	case probeWrite:
+		if kind == "dir" {
+			granted = eaccess(path, unix.W_OK|unix.X_OK)
+		} else {
+			granted = eaccess(path, unix.W_OK)
+		}
+	case probeExecute:
+		granted = eaccess(path, unix.X_OK)
+	case probeChmod:
+		// Owner or CAP_FOWNER
+		granted = euid == 0 || euid == stat.Uid
+	case probeChown:
+		// CAP_CHOWN
+		granted = euid == 0
+	case probeDelete:
+		// The parent must be writable, and with the sticky bit we must own the entry or the parent
+		parent := filepath.Dir(path)
+		var parentStat unix.Stat_t
+		if err := unix.Stat(parent, &parentStat); err != nil {
+			return rightUntested
+		}
+		granted = eaccess(parent, unix.W_OK|unix.X_OK)
+		if granted && parentStat.Mode&unix.S_ISVTX != 0 {
+			granted = euid == 0 || euid == stat.Uid || euid == parentStat.Uid
+		}
+	}
+	if granted {
+		return rightGranted
+	}
+	return rightDenied
+}
+
+func eaccess(path string, mode uint32) bool {
+	return unix.Faccessat(unix.AT_FDCWD, path, mode, unix.AT_EACCESS) == nil
+}
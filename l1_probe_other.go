@@ -0,0 +1,8 @@
+//go:build !windows && !linux
+
+package main
+
+// Nothing to probe, rights are unknown here
+func probeRights(kind string) []accessRight {
+	return nil
+}
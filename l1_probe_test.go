@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProbeAccess(t *testing.T) {
+	rights := probeRights("file")
+	if len(rights) < 3 {
+		t.Fatalf("expected at least 3 file rights, got %d", len(rights))
+	}
+
+	// Grant every critical right and the first one, the second is busy
+	granted := rights[0].mask
+	var wantRights, wantCritical []string
+	wantRights = append(wantRights, rights[0].name)
+	if rights[0].critical {
+		wantCritical = append(wantCritical, rights[0].name)
+	}
+	for _, right := range rights[2:] {
+		if right.critical {
+			granted |= right.mask
+			wantRights = append(wantRights, right.name)
+			wantCritical = append(wantCritical, right.name)
+		}
+	}
+	useFiles(t, fakeFiles{
+		rights: map[string]uint32{"/x": granted},
+		busy:   map[string]uint32{"/x": rights[1].mask},
+	})
+
+	result := probeAccess("/x", "file")
+	if result.Granted != granted {
+		t.Errorf("granted = %#x, want %#x", result.Granted, granted)
+	}
+	if !reflect.DeepEqual(result.Rights, wantRights) {
+		t.Errorf("rights = %v, want %v", result.Rights, wantRights)
+	}
+	if !reflect.DeepEqual(result.Critical, wantCritical) {
+		t.Errorf("critical = %v, want %v", result.Critical, wantCritical)
+	}
+	if !reflect.DeepEqual(result.Untested, []string{rights[1].name}) {
+		t.Errorf("untested = %v, want [%s]", result.Untested, rights[1].name)
+	}
+}
+
+func TestFormatProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *probeResult
+		want   string
+	}{
+		{"nil", nil, ""},
+		{"none", &probeResult{}, "  🔑 none [0x0]\n"},
+		{"critical", &probeResult{Granted: 0x6, Rights: []string{"read", "write"}, Critical: []string{"write"}}, "  🔑 read, 🚩 write [0x6]\n"},
+		{"untested", &probeResult{Granted: 0x4, Rights: []string{"read"}, Untested: []string{"delete"}}, "  🔑 read [0x4] (untested: delete)\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatProbe(test.result, "  "); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProbeRightsNamed(t *testing.T) {
+	for _, kind := range []string{"file", "dir"} {
+		for _, right := range probeRights(kind) {
+			if right.mask == 0 || strings.TrimSpace(right.name) == "" {
+				t.Errorf("%s right %+v has no mask or name", kind, right)
+			}
+		}
+	}
+}
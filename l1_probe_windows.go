@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/charlesgargasson/gofspy/sddl"
+	"golang.org/x/sys/windows"
+)
+
+const FILE_CREATE_PIPE_INSTANCE = 0x4
+
+// Specific rights first, then standard ones
+var probeMasks = map[string][]uint32{
+	"file": {0x1, 0x2, 0x4, 0x20, 0x100, windows.DELETE, windows.WRITE_DAC, windows.WRITE_OWNER},
+	"dir":  {0x1, 0x2, 0x4, 0x20, 0x40, windows.DELETE, windows.WRITE_DAC, windows.WRITE_OWNER},
+	"pipe": {0x1, 0x2, FILE_CREATE_PIPE_INSTANCE, windows.WRITE_DAC, windows.WRITE_OWNER, windows.DELETE, windows.SYNCHRONIZE},
+}
+
+// Rights giving control over the object or what it serves
+var criticalMasks = map[string]uint32{
+	"file": 0x2 | windows.WRITE_DAC | windows.WRITE_OWNER,
+	"dir":  0x2 | 0x4 | windows.WRITE_DAC | windows.WRITE_OWNER,
+	"pipe": FILE_CREATE_PIPE_INSTANCE | windows.WRITE_DAC | windows.WRITE_OWNER,
+}
+
+// probeRights are named as in ACLs
+func probeRights(kind string) []accessRight {
+	masks, found := probeMasks[kind]
+	if !found {
+		kind = "file"
+		masks = probeMasks[kind]
+	}
+	var rights []accessRight
+	for _, mask := range masks {
+		rights = append(rights, accessRight{
+			mask:     mask,
+			name:     sddl.FileRights(mask, kind)[0],
+			critical: criticalMasks[kind]&mask != 0,
+		})
+	}
+	return rights
+}
+
+// tryRight opens path with mask only
+func (windowsFiles) tryRight(path string, kind string, mask uint32) int {
+	var flags uint32
+	if kind == "dir" {
+		flags = windows.FILE_FLAG_BACKUP_SEMANTICS
+	}
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		mask,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		flags,
+		0,
+	)
+	switch err {
+	case nil:
+		windows.CloseHandle(handle)
+		return rightGranted
+	case windows.ERROR_ACCESS_DENIED:
+		return rightDenied
+	}
+	return rightUntested
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Named set of filters (-profile), loaded before the user filters
+type noiseProfile struct {
+	include []string
+	exclude []string
+	actions string
+}
+
+func builtinProfiles() map[string]*noiseProfile {
+	return map[string]*noiseProfile{
+		// Everything but fanotify opens, without the OS background noise
+		"quiet": {
+			exclude: slices.Clone(noisePatterns),
+			actions: "added,removed,modified,renamed,moved_out,moved_in,existing,closed_write,overflow",
+		},
+		// Only what brings new content, where a low privileged user could plant something
+		"privesc": {
+			exclude: slices.Concat(noisePatterns, privescNoisePatterns),
+			actions: "added,modified,renamed,moved_in,existing,closed_write,overflow",
+		},
+	}
+}
+
+func profileNames(profiles map[string]*noiseProfile) string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// loadProfileFile extends the profiles from a file
+//
+//	[name]
+//	exclude = pattern
+//	include = pattern
+//	action = list
+//
+// Patterns are added to the profile, the action list replaces it, unknown names create a new profile
+func loadProfileFile(fileName string, profiles map[string]*noiseProfile) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var profile *noiseProfile
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if name, found := strings.CutPrefix(line, "["); found {
+			name = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(name, "]")))
+			if profiles[name] == nil {
+				profiles[name] = &noiseProfile{}
+			}
+			profile = profiles[name]
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found || profile == nil {
+			return fmt.Errorf("%s:%d: expected [profile] or key = value", fileName, lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "include":
+			profile.include = append(profile.include, value)
+		case "exclude":
+			profile.exclude = append(profile.exclude, value)
+		case "action":
+			profile.actions = value
+		default:
+			return fmt.Errorf("%s:%d: unknown key %q (include, exclude, action)", fileName, lineNumber, key)
+		}
+	}
+	return scanner.Err()
+}
+
+// applyProfile merges a profile into the filter flags, an explicit -action wins over the profile one
+func applyProfile(name string, fileName string, include *stringList, exclude *stringList, actions *string) error {
+	profiles := builtinProfiles()
+	if fileName != "" {
+		err := loadProfileFile(fileName, profiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	profile, found := profiles[strings.ToLower(name)]
+	if !found {
+		return fmt.Errorf("unknown profile %q (valid: %s)", name, profileNames(profiles))
+	}
+
+	*include = slices.Concat(profile.include, *include)
+	*exclude = slices.Concat(profile.exclude, *exclude)
+	if *actions == "" {
+		*actions = profile.actions
+	}
+	return nil
+}
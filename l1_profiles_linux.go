@@ -0,0 +1,25 @@
+package main
+
+// Background noise of a Linux host, used by every built-in profile
+var noisePatterns = []string{
+	`*/.cache/*`,
+	`*/.mozilla/*`,
+	`*/.config/google-chrome/*`,
+	`*/.config/chromium/*`,
+	`*/.local/share/Trash/*`,
+	`*/go/pkg/mod/*`,
+	`*/.npm/*`,
+	`*/node_modules/*`,
+	`*/.bash_history`,
+	`*/.lesshst`,
+	`*/.viminfo`,
+	`*.swp`,
+	`*.swx`,
+}
+
+// Logs, mail queue and git internals, rarely a privesc path
+var privescNoisePatterns = []string{
+	`/var/spool/postfix/*`,
+	`*/.git/*`,
+	`*.log`,
+}
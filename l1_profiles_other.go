@@ -0,0 +1,14 @@
+//go:build !windows && !linux
+
+package main
+
+// Only the built-in patterns of the profiles
+var noisePatterns = []string{
+	`*.swp`,
+	`*.swx`,
+}
+
+var privescNoisePatterns = []string{
+	`*/.git/*`,
+	`*.log`,
+}
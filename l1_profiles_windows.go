@@ -0,0 +1,32 @@
+package main
+
+// Background noise of a Windows host, used by every built-in profile
+var noisePatterns = []string{
+	`*\Windows\Temp\*`,
+	`*\Windows\Prefetch\*`,
+	`*\Windows\SoftwareDistribution\*`,
+	`*\Windows\System32\config\*`,
+	`*\Windows\System32\LogFiles\*`,
+	`*\Windows\System32\sru\*`,
+	`*\ProgramData\Microsoft\Windows Defender\*`,
+	`*\ProgramData\Microsoft\Windows Defender Advanced Threat Protection\*`,
+	`*\ProgramData\Microsoft\Search\*`,
+	`*\AppData\Local\Google\Chrome\User Data\*`,
+	`*\AppData\Local\Microsoft\Edge\User Data\*`,
+	`*\AppData\Local\Mozilla\Firefox\Profiles\*`,
+	`*\AppData\Roaming\Mozilla\Firefox\Profiles\*`,
+	`*\AppData\Local\Microsoft\Windows\INetCache\*`,
+	`*\AppData\Local\Microsoft\Windows\WebCache\*`,
+	`*\AppData\Local\Microsoft\Windows\Explorer\*`,
+	`*\NTUSER.DAT*`,
+	`*\UsrClass.dat*`,
+}
+
+// Logs and component stores, rarely a privesc path
+var privescNoisePatterns = []string{
+	`*\Windows\Logs\*`,
+	`*\Windows\WinSxS\*`,
+	`*\Windows\ServiceProfiles\*`,
+	`*.log`,
+	`*.etl`,
+}
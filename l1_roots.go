@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charlesgargasson/gofspy/watch"
+)
+
+// parseRoot reads "path", "path|flat" or "path|depth=N"
+func parseRoot(spec string) (watch.Root, error) {
+	parts := strings.Split(spec, "|")
+	root := watch.Root{Path: strings.TrimSpace(parts[0]), Recursive: true}
+	if root.Path == "" {
+		return root, fmt.Errorf("empty path in %q", spec)
+	}
+	root.Path = filepath.Clean(root.Path)
+
+	for _, option := range parts[1:] {
+		option = strings.ToLower(strings.TrimSpace(option))
+		switch {
+		case option == "flat":
+			root.Recursive = false
+		case option == "recursive":
+			root.Recursive = true
+		case strings.HasPrefix(option, "depth="):
+			depth, err := strconv.Atoi(strings.TrimPrefix(option, "depth="))
+			if err != nil || depth < 0 {
+				return root, fmt.Errorf("invalid depth in %q", spec)
+			}
+			root.Recursive = true
+			root.Depth = depth
+		default:
+			return root, fmt.Errorf("unknown option %q in %q (flat, recursive, depth=N)", option, spec)
+		}
+	}
+	return root, nil
+}
+
+// readRootFile returns one root per line, empty lines and # comments are skipped
+func readRootFile(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+	return specs, scanner.Err()
+}
+
+// parseRoots returns the roots from -path and -pathfile, or the default roots when none
+func parseRoots(specs []string, fileName string) ([]watch.Root, error) {
+	if fileName != "" {
+		fileSpecs, err := readRootFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, fileSpecs...)
+	}
+
+	var roots []watch.Root
+	for _, spec := range specs {
+		root, err := parseRoot(spec)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+
+	if len(roots) == 0 {
+		for _, path := range defaultRoots() {
+			roots = append(roots, watch.Root{Path: path, Recursive: true})
+		}
+	}
+	return roots, nil
+}
+
+func rootPaths(roots []watch.Root) []string {
+	var paths []string
+	for _, root := range roots {
+		paths = append(paths, root.Path)
+	}
+	return paths
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// A path is handled once it wasn't modified for settleDebounce,
+// paths written without a pause are handled settleMaxWait after their first modification
+const (
+	settleDebounce = 500 * time.Millisecond
+	settleMaxWait  = 10 * time.Second
+)
+
+type settleJob[T any] struct {
+	path    string
+	waiters []chan T
+	first   time.Time   // first modification
+	timer   *time.Timer // sends the job to the queue
+	queued  bool        // the timer fired, modifications can't push it back anymore
+	changed bool        // modified once queued, handled again
+}
+
+// Bounded pool of workers running work on paths once their writes settle (hashes, captures)
+// Requests for a path with a pending job are merged into it, and push it back
+// Jobs wait for a free worker, none is dropped
+type settlePool[T any] struct {
+	mu      sync.Mutex
+	pending map[string]*settleJob[T]
+	queue   chan *settleJob[T]
+	work    func(path string) T
+}
+
+func newSettlePool[T any](workers int, work func(path string) T) *settlePool[T] {
+	pool := &settlePool[T]{
+		pending: make(map[string]*settleJob[T]),
+		queue:   make(chan *settleJob[T]),
+		work:    work,
+	}
+	for range max(workers, 1) {
+		go pool.worker()
+	}
+	return pool
+}
+
+// request returns a channel receiving the result of work on path, once it settled
+func (pool *settlePool[T]) request(path string) chan T {
+	result := make(chan T, 1)
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if job, found := pool.pending[path]; found {
+		job.waiters = append(job.waiters, result)
+		if job.queued {
+			job.changed = true
+		} else if time.Since(job.first) < settleMaxWait {
+			job.timer.Reset(settleDebounce)
+		}
+		return result
+	}
+
+	job := &settleJob[T]{path: path, waiters: []chan T{result}, first: time.Now()}
+	pool.pending[path] = job
+	job.timer = time.AfterFunc(settleDebounce, func() {
+		pool.mu.Lock()
+		job.queued = true
+		pool.mu.Unlock()
+		pool.queue <- job
+	})
+	return result
+}
+
+func (pool *settlePool[T]) worker() {
+	for job := range pool.queue {
+		pool.mu.Lock()
+		job.changed = false
+		pool.mu.Unlock()
+
+		result := pool.work(job.path)
+
+		// Modified meanwhile, every waiter gets the result of the final content
+		pool.mu.Lock()
+		if job.changed && time.Since(job.first) < settleMaxWait {
+			job.queued = false
+			job.timer.Reset(settleDebounce)
+			pool.mu.Unlock()
+			continue
+		}
+		// From now on, new requests for this path need a new run
+		delete(pool.pending, job.path)
+		waiters := job.waiters
+		pool.mu.Unlock()
+
+		for _, waiter := range waiters {
+			waiter <- result
+		}
+	}
+}
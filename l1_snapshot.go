@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Baseline of a directory tree, saved with -snapshot and compared with -diff
+type snapshot struct {
+	Time    time.Time       `json:"time"`
+	Roots   []string        `json:"roots"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Path       string    `json:"path"`
+	Dir        bool      `json:"dir"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mtime"`
+	Attributes uint32    `json:"attributes"`
+	Owner      string    `json:"owner"`              // for display, it falls back to the id when a lookup fails
+	OwnerId    string    `json:"owner_id,omitempty"` // SID or uid
+	Access     string    `json:"access"`
+}
+
+// takeSnapshot walks the roots, owner and access are resolved by a pool of workers
+func takeSnapshot(roots []string) snapshot {
+	snap := snapshot{Time: time.Now(), Roots: roots}
+
+	paths := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for range runtime.NumCPU() * 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range paths {
+				mu.Lock()
+				entry := snap.Entries[index]
+				mu.Unlock()
+
+				owner_ch := make(chan fileOwner)
+				go getFileOwner(entry.Path, owner_ch)
+				if !entry.Dir {
+					_, _, entry.Access = checkFileAccess(entry.Path)
+				}
+				owner := <-owner_ch
+				entry.Owner, entry.OwnerId = owner.name, owner.id
+
+				mu.Lock()
+				snap.Entries[index] = entry
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, dirEntry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			info, err := dirEntry.Info()
+			if err != nil {
+				return nil
+			}
+
+			mu.Lock()
+			snap.Entries = append(snap.Entries, snapshotEntry{
+				Path:       path,
+				Dir:        dirEntry.IsDir(),
+				Size:       info.Size(),
+				ModTime:    info.ModTime(),
+				Attributes: fileAttributes(info),
+			})
+			index := len(snap.Entries) - 1
+			mu.Unlock()
+
+			paths <- index
+			return nil
+		})
+	}
+	close(paths)
+	wg.Wait()
+
+	sort.Slice(snap.Entries, func(i, j int) bool {
+		return snap.Entries[i].Path < snap.Entries[j].Path
+	})
+	return snap
+}
+
+func saveSnapshot(fileName string, roots []string) {
+	printNotice("[*] Snapshot of %v\n", roots)
+	snap := takeSnapshot(roots)
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		printNotice("[*] Can't encode snapshot (%v)\n", err)
+		return
+	}
+	err = os.WriteFile(fileName, data, 0600)
+	if err != nil {
+		printNotice("[*] Can't write snapshot (%v)\n", err)
+		return
+	}
+	printNotice("[*] Saved %d entries to %s\n", len(snap.Entries), fileName)
+}
+
+// snapshotChanges lists what differs between two states of the same entry
+func snapshotChanges(before snapshotEntry, after snapshotEntry) []string {
+	var changes []string
+	if !after.Dir && before.Size != after.Size {
+		changes = append(changes, fmt.Sprintf("size %d → %d", before.Size, after.Size))
+	}
+	if !after.Dir && !before.ModTime.Equal(after.ModTime) {
+		changes = append(changes, fmt.Sprintf("mtime %s → %s", before.ModTime.Format(time.RFC3339Nano), after.ModTime.Format(time.RFC3339Nano)))
+	}
+	if before.Attributes != after.Attributes {
+		changes = append(changes, fmt.Sprintf("attributes %#x → %#x", before.Attributes, after.Attributes))
+	}
+	// Names depend on lookups, ids don't, older snapshots only have names
+	if before.OwnerId != "" && after.OwnerId != "" {
+		if before.OwnerId != after.OwnerId {
+			changes = append(changes, fmt.Sprintf("owner %s → %s", before.Owner, after.Owner))
+		}
+	} else if before.Owner != after.Owner {
+		changes = append(changes, fmt.Sprintf("owner %s → %s", before.Owner, after.Owner))
+	}
+	if before.Access != after.Access {
+		changes = append(changes, fmt.Sprintf("access %s → %s", before.Access, after.Access))
+	}
+	return changes
+}
+
+func snapshotEvent(entry snapshotEntry, action uint32, givenTime time.Time) fsEvent {
+	event := newFileEvent(entry.Path, action, givenTime)
+	if entry.Dir {
+		event.Kind = "dir"
+	}
+	event.Owner = entry.Owner
+	event.OwnerId = entry.OwnerId
+	event.Access = entry.Access
+	return event
+}
+
+// diffSnapshot compares the current state of the saved roots against the snapshot
+func diffSnapshot(fileName string) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		printNotice("[*] Can't read snapshot (%v)\n", err)
+		return
+	}
+	var before snapshot
+	err = json.Unmarshal(data, &before)
+	if err != nil {
+		printNotice("[*] Can't decode snapshot (%v)\n", err)
+		return
+	}
+
+	printNotice("[*] Comparing %v with snapshot from %s\n", before.Roots, before.Time.Format(time.RFC3339))
+	after := takeSnapshot(before.Roots)
+	currentTime := time.Now()
+
+	beforeEntries := make(map[string]snapshotEntry, len(before.Entries))
+	for _, entry := range before.Entries {
+		beforeEntries[entry.Path] = entry
+	}
+	afterEntries := make(map[string]snapshotEntry, len(after.Entries))
+	for _, entry := range after.Entries {
+		afterEntries[entry.Path] = entry
+	}
+
+	var events []fsEvent
+	for _, entry := range after.Entries {
+		old, found := beforeEntries[entry.Path]
+		if !found {
+			events = append(events, snapshotEvent(entry, FILE_ACTION_ADDED, currentTime))
+			continue
+		}
+		if changes := snapshotChanges(old, entry); len(changes) > 0 {
+			event := snapshotEvent(entry, FILE_ACTION_MODIFIED, currentTime)
+			event.Changes = changes
+			events = append(events, event)
+		}
+	}
+	for _, entry := range before.Entries {
+		if _, found := afterEntries[entry.Path]; !found {
+			// Owner and access are from the snapshot, keep them for context
+			events = append(events, snapshotEvent(entry, FILE_ACTION_REMOVED, currentTime))
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	// Totals count the listed entries only
+	counts := make(map[uint32]int)
+	shown := 0
+	for _, event := range events {
+		if !filter.allowPath(event.Path, event.actionCode, 0) || !filter.allowEvent(event) {
+			continue
+		}
+		counts[event.actionCode]++
+		shown++
+		reportFileEvent(event, 0)
+	}
+
+	printNotice("[*] %d changes (🟢 %d, ❌ %d, 🟠 %d)\n", shown, counts[FILE_ACTION_ADDED], counts[FILE_ACTION_REMOVED], counts[FILE_ACTION_MODIFIED])
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffSnapshotCountsFiltered(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "gone.txt"), []byte("a"), 0o600)
+	os.WriteFile(filepath.Join(root, "gone.log"), []byte("a"), 0o600)
+	snapshotFile := filepath.Join(t.TempDir(), "snap.json")
+	captureStdout(t, func() { saveSnapshot(snapshotFile, []string{root}) })
+
+	os.Remove(filepath.Join(root, "gone.txt"))
+	os.Remove(filepath.Join(root, "gone.log"))
+	os.WriteFile(filepath.Join(root, "new.log"), []byte("a"), 0o600)
+
+	saved := filter
+	defer func() { filter = saved }()
+	var err error
+	filter, err = newEventFilter(nil, []string{"*.log"}, nil, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() { diffSnapshot(snapshotFile) })
+	if strings.Contains(output, ".log") {
+		t.Errorf("filtered entries listed:\n%s", output)
+	}
+	if !strings.Contains(output, "[*] 1 changes (🟢 0, ❌ 1, 🟠 0)") {
+		t.Errorf("totals don't match the listed entries:\n%s", output)
+	}
+}
+
+func TestSnapshotChangesOwnerById(t *testing.T) {
+	resolved := snapshotEntry{Path: "/a", Owner: `CORP\alice`, OwnerId: "S-1-5-21-1-2-3-1001"}
+	timedOut := snapshotEntry{Path: "/a", Owner: "S-1-5-21-1-2-3-1001", OwnerId: "S-1-5-21-1-2-3-1001"}
+	if changes := snapshotChanges(resolved, timedOut); len(changes) > 0 {
+		t.Errorf("same owner reported as %v", changes)
+	}
+
+	other := snapshotEntry{Path: "/a", Owner: `CORP\bob`, OwnerId: "S-1-5-21-1-2-3-1002"}
+	if changes := snapshotChanges(resolved, other); len(changes) != 1 || changes[0] != `owner CORP\alice → CORP\bob` {
+		t.Errorf("changes %v", changes)
+	}
+
+	// Snapshots without ids compare names
+	old := snapshotEntry{Path: "/a", Owner: `CORP\bob`}
+	if changes := snapshotChanges(old, resolved); len(changes) != 1 {
+		t.Errorf("changes %v", changes)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Activity counters (-stats), nil when disabled
+var stats *eventStats
+
+// Rows per table
+const statsTop = 10
+
+// Per path counters are pruned above this, entries seen once go first
+const statsMaxPaths = 100000
+
+type eventStats struct {
+	mu           sync.Mutex
+	started      time.Time
+	total        int
+	actions      map[string]int
+	dirs         map[string]int
+	files        map[string]int // changes per file
+	pipesAdded   int
+	pipesRemoved int
+}
+
+func newEventStats() *eventStats {
+	return &eventStats{
+		started: time.Now(),
+		actions: make(map[string]int),
+		dirs:    make(map[string]int),
+		files:   make(map[string]int),
+	}
+}
+
+// record counts a reported event
+func (s *eventStats) record(event fsEvent) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	s.actions[event.Action]++
+
+	switch {
+	case event.Kind == "pipe":
+		if event.actionCode == FILE_ACTION_ADDED {
+			s.pipesAdded++
+		} else if event.actionCode == FILE_ACTION_REMOVED {
+			s.pipesRemoved++
+		}
+	case event.actionCode != FILE_ACTION_OVERFLOW:
+		s.dirs[filepath.Dir(event.Path)]++
+		if event.Kind != "dir" && shouldHash(event.actionCode) {
+			s.files[event.Path]++
+		}
+		prunePaths(s.dirs)
+		prunePaths(s.files)
+	}
+}
+
+// prunePaths keeps counters bounded on long sessions
+func prunePaths(counters map[string]int) {
+	if len(counters) <= statsMaxPaths {
+		return
+	}
+	for path, count := range counters {
+		if count <= 1 {
+			delete(counters, path)
+		}
+	}
+	// Still too many, every path is busy: start over
+	if len(counters) > statsMaxPaths {
+		clear(counters)
+	}
+}
+
+type statsRow struct {
+	name  string
+	count int
+}
+
+// topCounters returns the biggest counters, ties sorted by name
+func topCounters(counters map[string]int, limit int) []statsRow {
+	var rows []statsRow
+	for name, count := range counters {
+		rows = append(rows, statsRow{name, count})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].count != rows[j].count {
+			return rows[i].count > rows[j].count
+		}
+		return rows[i].name < rows[j].name
+	})
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// print writes the summary table, on stderr when stdout holds JSON
+func (s *eventStats) print(title string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out io.Writer = os.Stdout
+	if jsonOutput {
+		out = os.Stderr
+	}
+
+	now := time.Now()
+	fmt.Fprintf(out, "\n📊 %s %s, %d events since %s (%s)\n", timeFormat(now), title, s.total, timeFormat(s.started), now.Sub(s.started).Round(time.Second))
+
+	var actions []string
+	for _, row := range topCounters(s.actions, 0) {
+		actions = append(actions, fmt.Sprintf("%s %d", row.name, row.count))
+	}
+	fmt.Fprintf(out, "    Actions : %s\n", strings.Join(actions, ", "))
+	if s.pipesAdded > 0 || s.pipesRemoved > 0 {
+		fmt.Fprintf(out, "    Pipes   : 🟢 %d new, ❌ %d removed\n", s.pipesAdded, s.pipesRemoved)
+	}
+	if enricher != nil {
+		fmt.Fprintf(out, "    Enrich  : %s\n", enricher.summary())
+	}
+
+	if len(s.dirs) > 0 {
+		fmt.Fprintf(out, "    Top directories\n")
+		for _, row := range topCounters(s.dirs, statsTop) {
+			fmt.Fprintf(out, "    %8d  %s\n", row.count, row.name)
+		}
+	}
+	if len(s.files) > 0 {
+		fmt.Fprintf(out, "    Most changed files\n")
+		for _, row := range topCounters(s.files, statsTop) {
+			fmt.Fprintf(out, "    %8d  %s\n", row.count, row.name)
+		}
+	}
+	fmt.Fprintf(out, "\n")
+}
+
+// run prints the summary every interval
+func (s *eventStats) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.print("Summary")
+	}
+}
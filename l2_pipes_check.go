@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlesgargasson/gofspy/ipc"
+	"github.com/charlesgargasson/gofspy/pipes"
+)
+
+// Named pipes client and server only exist on Windows, tests with an in-memory transport set it
+var pipesSupported = pipes.Supported
+
+// Listing, checking and watching also work on Linux, with Unix sockets and FIFOs as pipes
+var pipesListed = pipes.Supported || ipc.Supported
+
+var errPipesUnsupported = pipes.ErrUnsupported
+
+func pipesUnsupported(pipeName string) {
+	event := pipeErrorEvent(pipeName, errPipesUnsupported)
+	printEvent(event, "💧 %s 🔴 %v\n", timeFormat(time.Now()), errPipesUnsupported)
+}
+
+// runPipeMode is runMode for the pipe modes, elsewhere than Windows it only says why they can't run
+func runPipeMode(ctx context.Context, pipeName string, mode func(context.Context)) {
+	if !pipesSupported {
+		pipesUnsupported(pipeName)
+		return
+	}
+	runMode(ctx, mode)
+}
+
+func checkPipe(ctx context.Context, pipeName string) {
+	if !pipesListed {
+		pipesUnsupported(pipeName)
+		return
+	}
+
+	// Before inspect, each try takes a pipe instance
+	var probe *probeResult
+	if accessProbe {
+		probe = probeAccess(pipeName, "pipe")
+	}
+
+	var hijackable bool
+	var hijackErr error
+	if hijack > 0 {
+		server, err := osPipes.create(pipeName)
+		if err != nil {
+			hijackErr = err
+		} else {
+			server.Close()
+			hijackable = true
+		}
+	}
+
+	// Get informations of server pipe handle
+	details := osPipes.inspect(pipeName)
+
+	if jsonOutput {
+		printEvent(pipeCheckEvent(pipeName, details, probe, hijackable, hijackErr), "")
+	} else {
+		printPipeCheck(details, probe, hijackable, hijackErr)
+	}
+
+	// MiTM Server
+
+	if hijack == 2 {
+		printNotice("\n")
+		startServerHJ(ctx, pipeName)
+	}
+}
+
+// pipeCheckEvent holds the results of -check, printed as one event with -json
+func pipeCheckEvent(pipeName string, details pipeDetails, probe *probeResult, hijackable bool, hijackErr error) fsEvent {
+	event := pipeEvent(pipeName, "check")
+	event.Access = details.Access
+	event.Hijackable = hijackable
+	if hijackErr != nil {
+		event.Error = hijackErr.Error()
+	}
+	event.Probe = probe
+
+	if details.Control {
+		event.Owner, event.OwnerId = details.Owner, details.OwnerId
+		event.Acl = details.Acl
+		event.Process = details.Process
+		if event.Process == nil && details.Pid > 0 {
+			event.Process = &procInfo{Pid: int(details.Pid), Uid: -1}
+		}
+	}
+	return event
+}
+
+// printPipeCheck is the text output of -check
+func printPipeCheck(details pipeDetails, probe *probeResult, hijackable bool, hijackErr error) {
+	if hijackErr != nil {
+		fmt.Printf("💧 %s 🔴 Can't Hijack (%v)\n", timeFormat(time.Now()), hijackErr)
+	} else if hijackable {
+		fmt.Printf("💧 %s 🟢 Hijackable \n", timeFormat(time.Now()))
+	}
+
+	// print infos
+
+	if details.Control {
+		if details.Pid > uint32(0) {
+			fmt.Printf("💧 %s ⚪ Pid: %d\n", timeFormat(time.Now()), details.Pid)
+		}
+		if details.Process != nil && details.Process.Exe != "" {
+			fmt.Printf("💧 %s ⚪ Process: %s\n", timeFormat(time.Now()), details.Process.Exe)
+		}
+		if details.Owner != "" {
+			fmt.Printf("💧 %s ⚪ Owner: [%s]\n", timeFormat(time.Now()), accounts.label(details.Owner, details.OwnerId))
+		}
+		if len(details.Acl) > 0 {
+			fmt.Printf("💧 %s ⚪ ACL:\n", timeFormat(time.Now()))
+			fmt.Print(formatAcl(details.Acl, "      "))
+		}
+		if details.HasState {
+			if details.State != "" {
+				fmt.Printf("💧 %s ⚪ State: %s\n", timeFormat(time.Now()), details.State)
+			}
+			fmt.Printf("💧 %s ⚪ Pipes: %d\n", timeFormat(time.Now()), details.Instances)
+		}
+	}
+
+	if probe != nil {
+		fmt.Printf("💧 %s ⚪ Access:\n%s", timeFormat(time.Now()), formatProbe(probe, "      "))
+	}
+
+	if details.Read {
+		fmt.Printf("💧 %s 🟢 Readable \n", timeFormat(time.Now()))
+	} else {
+		fmt.Printf("💧 %s 🔴 Can't read \n", timeFormat(time.Now()))
+	}
+
+	if details.Write {
+		fmt.Printf("💧 %s 🟢 Writable \n", timeFormat(time.Now()))
+	} else {
+		fmt.Printf("💧 %s 🔴 Can't write \n", timeFormat(time.Now()))
+	}
+}
@@ -1,15 +1,13 @@
 package main
 
 import (
-	"fmt"
-	"syscall"
+	"context"
 	"time"
-	"sync"
 
-	"golang.org/x/sys/windows"
+	"github.com/charlesgargasson/gofspy/pipes"
 )
 
-func exhaustPipe(pipeName string, exhaustType int) {
+func exhaustPipe(ctx context.Context, pipeName string, exhaustType int) {
 	// 1: exhaust pool, keep handles open
 	// 2: speed exhaust, keep it stuck with many requests
 	exhaustLimit := 4097
@@ -17,56 +15,45 @@ func exhaustPipe(pipeName string, exhaustType int) {
 	failed := 0
 	stuck := false
 	if exhaustType == 1 {
-		for exhaustcpt < exhaustLimit {
+		for exhaustcpt < exhaustLimit && ctx.Err() == nil {
 			// Open the named pipe with READ_CONTROL
-			handle, err := windows.CreateFile(
-				syscall.StringToUTF16Ptr(pipeName),
-				windows.READ_CONTROL,
-				0,
-				nil,
-				windows.OPEN_EXISTING,
-				0,
-				0,
-			)
-			defer windows.CloseHandle(handle)
+			client, err := osPipes.open(pipeName, pipes.Control)
 			if err != nil {
 				failed++
 				if failed > 10 {
-					fmt.Printf("💧 %s 🟢 Probably exhausted, with %d active handles (%v)\n", timeFormat(time.Now()), exhaustcpt, err)
-					fmt.Println("Error message:", err)
-					select {}
+					printNotice("💧 %s 🟢 Probably exhausted, with %d active handles (%v)\n", timeFormat(time.Now()), exhaustcpt, err)
+					printNotice("Error message: %v\n", err)
+					// Handles are released on return
+					<-ctx.Done()
+					return
 				}
 			} else {
+				defer client.Close()
 				exhaustcpt++
 			}
 			time.Sleep(10 * time.Millisecond)
 		}
-		fmt.Printf("💧 %s 🔴 Reached limit \n", timeFormat(time.Now()))
+		if ctx.Err() == nil {
+			printNotice("💧 %s 🔴 Reached limit \n", timeFormat(time.Now()))
+			<-ctx.Done()
+		}
 
 	} else if exhaustType == 2 {
-		for {
+		for ctx.Err() == nil {
 			// Open the named pipe with READ_CONTROL
-			handle, err := windows.CreateFile(
-				syscall.StringToUTF16Ptr(pipeName),
-				windows.READ_CONTROL,
-				0,
-				nil,
-				windows.OPEN_EXISTING,
-				0,
-				0,
-			)
-			defer windows.CloseHandle(handle)
+			client, err := osPipes.open(pipeName, pipes.Control)
 			if err != nil {
 				failed++
 				if !stuck && failed > 5 {
-					fmt.Printf("💧 %s 🟢 Probably exhausted, %d active handles (%v)\n", timeFormat(time.Now()), exhaustcpt, err)
+					printNotice("💧 %s 🟢 Probably exhausted, %d active handles (%v)\n", timeFormat(time.Now()), exhaustcpt, err)
 					stuck = true
 				}
 			} else {
+				defer client.Close()
 				failed = 0
 				exhaustcpt++
 				if stuck {
-					fmt.Printf("💧 %s 🔴 Server is alive again (%d active handles) \n", timeFormat(time.Now()), exhaustcpt)
+					printNotice("💧 %s 🔴 Server is alive again (%d active handles) \n", timeFormat(time.Now()), exhaustcpt)
 					stuck = false
 				}
 			}
@@ -74,195 +61,95 @@ func exhaustPipe(pipeName string, exhaustType int) {
 	}
 }
 
-func checkPipe(pipeName string, isexit chan bool) {
-	handle, readAccess, writeAccess, _, controlAccess, _ := bestFileHandle(pipeName)
-
-	if hijack > 0 {
-		hijackHandle, err := createDuplexPipe(pipeName)
-		windows.CloseHandle(hijackHandle)
-		if err != nil {
-			fmt.Printf("💧 %s 🔴 Can't Hijack (%v)\n", timeFormat(time.Now()), err)
-		} else {
-			fmt.Printf("💧 %s 🟢 Hijackable \n", timeFormat(time.Now()))
-		}
-	}
-
-	// Get informations of server pipe handle
-	var wg sync.WaitGroup
-	var pid uint32
-	var owner string
-	var namedPipeHandleState namedPipeHandleStateStruct
-
-	if controlAccess {
-
-		wg.Add(3)
-		go GetNamedPipeServerPID(handle, &pid, &wg)
-		go getHandleOwner(handle, &owner, &wg)
-		go GetNamedPipeHandleState(handle, &namedPipeHandleState, &wg)
-
-	}
-
-	wg.Wait()
-	
-	windows.CloseHandle(handle)
-
-	// print infos
-
-	if controlAccess {
-		if pid > uint32(0) {
-			fmt.Printf("💧 %s ⚪ Pid: %d\n", timeFormat(time.Now()), pid)
-		}
-		if owner != "" {
-			fmt.Printf("💧 %s ⚪ Owner: %s\n", timeFormat(time.Now()), owner)
-		}
-		if namedPipeHandleState.success {
-			switch namedPipeHandleState.state {
-			case uint32(0):
-				fmt.Printf("💧 %s ⚪ State: WAIT\n", timeFormat(time.Now()))
-			case windows.PIPE_NOWAIT:
-				fmt.Printf("💧 %s ⚪ State: NOWAIT\n", timeFormat(time.Now()))
-			case windows.PIPE_READMODE_MESSAGE:
-				fmt.Printf("💧 %s ⚪ State: MESSAGE\n", timeFormat(time.Now()))
-			}
-			
-			fmt.Printf("💧 %s ⚪ Pipes: %d\n", timeFormat(time.Now()), namedPipeHandleState.curInstances)
-			//fmt.Printf("💧 %s ⚪ Max data: %d bytes\n", timeFormat(time.Now()), namedPipeHandleState.maxCollectionCount)
-			//fmt.Printf("💧 %s ⚪ Timeout: %d\n", timeFormat(time.Now()), namedPipeHandleState.collectDataTimeout)
-			//fmt.Printf("💧 %s ⚪ User: %s\n", timeFormat(time.Now()), namedPipeHandleState.userName)
-		}
-	}
-
-	if readAccess {
-		fmt.Printf("💧 %s 🟢 Readable \n", timeFormat(time.Now()))
-	} else {
-		fmt.Printf("💧 %s 🔴 Can't read \n", timeFormat(time.Now()))
-	}
-
-	if writeAccess {
-		fmt.Printf("💧 %s 🟢 Writable \n", timeFormat(time.Now()))
-	} else {
-		fmt.Printf("💧 %s 🔴 Can't write \n", timeFormat(time.Now()))
-	}
-
-	// MiTM Server
-
-	if hijack == 2 {
-		fmt.Printf("\n")
-		startServerHJ(pipeName)
-	}
-
-	isexit <- true
-}
-
-func readFromPipe(pipeName string, isexit chan bool) {
+func readFromPipe(ctx context.Context, pipeName string) {
 	// Open the named pipe
-	handle, err := windows.CreateFile(
-		syscall.StringToUTF16Ptr(pipeName),
-		windows.GENERIC_READ,
-		0,
-		nil,
-		windows.OPEN_EXISTING,
-		0,
-		0,
-	)
-
-	defer windows.CloseHandle(handle)
-
+	client, err := osPipes.open(pipeName, pipes.Read)
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Can't retrieve Read handle (%v)\n", timeFormat(time.Now()), err)
-		isexit <- true
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Can't retrieve Read handle (%v)\n", timeFormat(event.Time), err)
 		return
 	}
+	defer client.Close()
 
-	fmt.Printf("💧 %s 🟢 Read handle \n", timeFormat(time.Now()))
+	event := pipeEvent(pipeName, "connected")
+	printEvent(event, "💧 %s 🟢 Read handle \n", timeFormat(event.Time))
 
 	for {
-		data, err := readFromHandle(handle, false)
+		// A pending read only ends with data, an error or a cancellation
+		data, err := client.ReadMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			fmt.Printf("\n💧 %s 🔴 Can't read (%v)\n", timeFormat(time.Now()), err)
-			isexit <- true
+			event := pipeErrorEvent(pipeName, err)
+			printEvent(event, "\n💧 %s 🔴 Can't read (%v)\n", timeFormat(event.Time), err)
 			return
 		}
 
 		// Print the data read from the named pipe
-		fmt.Printf("💧 %s 🟠 received %d bytes %q\n", timeFormat(time.Now()), len(data), data)
+		event := pipeDataEvent(pipeName, "received", data)
+		printEvent(event, "💧 %s 🟠 received %d bytes %q\n", timeFormat(event.Time), len(data), data)
 	}
-
-	isexit <- true
 }
 
-func writeToPipe(pipeName string, data []byte, isexit chan bool) {
+func writeToPipe(ctx context.Context, pipeName string, data []byte) {
 	// Open the named pipe
-	handle, err := windows.CreateFile(
-		syscall.StringToUTF16Ptr(pipeName),
-		windows.GENERIC_WRITE,
-		0,
-		nil,
-		windows.OPEN_EXISTING,
-		0,
-		0,
-	)
-
-	defer windows.CloseHandle(handle)
-
+	client, err := osPipes.open(pipeName, pipes.Write)
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Can't retrieve Write handle (%v)\n", timeFormat(time.Now()), err)
-		isexit <- true
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Can't retrieve Write handle (%v)\n", timeFormat(event.Time), err)
 		return
 	}
+	defer client.Close()
 
-	fmt.Printf("💧 %s 🟢 Write handle on %s\n", timeFormat(time.Now()), pipeName)
+	event := pipeEvent(pipeName, "connected")
+	printEvent(event, "💧 %s 🟢 Write handle on %s\n", timeFormat(event.Time), pipeName)
 
-	err = writeToHandle(handle, data, false)
+	err = client.WriteMessage(data)
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Can't send data (%v) \n", timeFormat(time.Now()), err)
-		isexit <- true
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Can't send data (%v) \n", timeFormat(event.Time), err)
 		return
 	}
-	fmt.Printf("💧 %s 🟠 Sent %q\n", timeFormat(time.Now()), data)
-	isexit <- true
+	event = pipeDataEvent(pipeName, "sent", data)
+	printEvent(event, "💧 %s 🟠 Sent %q\n", timeFormat(event.Time), data)
 }
 
-func writeReadToPipe(pipeName string, data []byte, isexit chan bool) {
-	handle, err := windows.CreateFile(
-		syscall.StringToUTF16Ptr(pipeName),
-		windows.GENERIC_READ|windows.GENERIC_WRITE,
-		0,
-		nil,
-		windows.OPEN_EXISTING,
-		0,
-		0,
-	)
-
-	defer windows.CloseHandle(handle)
-
+func writeReadToPipe(ctx context.Context, pipeName string, data []byte) {
+	client, err := osPipes.open(pipeName, pipes.Read|pipes.Write)
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Can't retrieve Read/Write handle (%v)\n", timeFormat(time.Now()), err)
-		isexit <- true
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Can't retrieve Read/Write handle (%v)\n", timeFormat(event.Time), err)
 		return
 	}
+	defer client.Close()
 
-	fmt.Printf("💧 %s 🟢 Read/Write handle on %s\n", timeFormat(time.Now()), pipeName)
+	event := pipeEvent(pipeName, "connected")
+	printEvent(event, "💧 %s 🟢 Read/Write handle on %s\n", timeFormat(event.Time), pipeName)
 
-	err = writeToHandle(handle, data, false)
+	err = client.WriteMessage(data)
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Can't send data (%v) \n", timeFormat(time.Now()), err)
-		isexit <- true
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Can't send data (%v) \n", timeFormat(event.Time), err)
 		return
 	}
-	fmt.Printf("💧 %s 🟠 Sent: %q\n", timeFormat(time.Now()), data)
+	event = pipeDataEvent(pipeName, "sent", data)
+	printEvent(event, "💧 %s 🟠 Sent: %q\n", timeFormat(event.Time), data)
 
 	for {
-		data, err := readFromHandle(handle, false)
+		// A pending read only ends with data, an error or a cancellation
+		data, err := client.ReadMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			fmt.Printf("\n💧 %s 🔴 Can't read (%v)\n", timeFormat(time.Now()), err)
-			isexit <- true
+			event := pipeErrorEvent(pipeName, err)
+			printEvent(event, "\n💧 %s 🔴 Can't read (%v)\n", timeFormat(event.Time), err)
 			return
 		}
 
 		// Print the data read from the named pipe
-		fmt.Printf("💧 %s 🟠 received %d bytes %q\n", timeFormat(time.Now()), len(data), data)
+		event := pipeDataEvent(pipeName, "received", data)
+		printEvent(event, "💧 %s 🟠 received %d bytes %q\n", timeFormat(event.Time), len(data), data)
 	}
-
-	isexit <- true
 }
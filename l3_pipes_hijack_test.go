@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// relay starts handleClientHJ between two in-memory pipes
+// It returns the victim client end and the real server end
+func relay(t *testing.T, ctx context.Context) (net.Conn, net.Conn, chan struct{}) {
+	client, clientOurs := net.Pipe()
+	server, serverOurs := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleClientHJ(ctx, serverOurs, clientOurs, `\\.\pipe\test`, 1)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server, done
+}
+
+func readWithin(t *testing.T, conn net.Conn, size int) string {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buffer := make([]byte, size)
+	n, err := io.ReadFull(conn, buffer)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(buffer[:n])
+}
+
+func waitDone(t *testing.T, done chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("relay still running")
+	}
+}
+
+func TestRelayBothWays(t *testing.T) {
+	var client, server net.Conn
+	var done chan struct{}
+	captureStdout(t, func() {
+		client, server, done = relay(t, context.Background())
+
+		go client.Write([]byte("hello"))
+		if got := readWithin(t, server, 5); got != "hello" {
+			t.Errorf("server got %q", got)
+		}
+
+		go server.Write([]byte("world"))
+		if got := readWithin(t, client, 5); got != "world" {
+			t.Errorf("client got %q", got)
+		}
+
+		// The client leaving ends the session, and our connection to the server
+		client.Close()
+		waitDone(t, done)
+	})
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("server read after end = %v, want EOF", err)
+	}
+}
+
+func TestRelayCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	output := captureStdout(t, func() {
+		_, _, done := relay(t, ctx)
+		cancel()
+		waitDone(t, done)
+	})
+	if output == "" {
+		t.Error("no session output")
+	}
+}
+
+func TestStartServerHJ(t *testing.T) {
+	memory := newMemoryPipes()
+	usePipes(t, memory)
+	name := `\\.\pipe\svc`
+	received := serveOnce(t, memory, name, "pong")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := captureStdout(t, func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			startServerHJ(ctx, name)
+		}()
+
+		// Once we are connected to the real server, our instance is the next one served
+		memory.waitDials(t, name, 1)
+		victim, err := memory.dial(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		victim.Write([]byte("secret"))
+		_, reply, _ := readFromConn(victim)
+		if string(reply) != "pong" {
+			t.Errorf("victim got %q", reply)
+		}
+		if got := <-received; got != "secret" {
+			t.Errorf("server got %q", got)
+		}
+		victim.Close()
+		cancel()
+		<-done
+	})
+	for _, want := range []string{`⚪ [000] Connected to \\.\pipe\svc`, `⚪ [000] Hijacking new client`, `6B TO \\.\pipe\svc: "secret"`, `4B FROM \\.\pipe\svc: "pong"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("missing %q in\n%s", want, output)
+		}
+	}
+}
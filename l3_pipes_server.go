@@ -4,30 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
-	"syscall"
 	"time"
-
-	"golang.org/x/sys/windows"
 )
 
-func createDuplexPipe(pipeName string) (windows.Handle, error) {
-	handle, err := windows.CreateNamedPipe(
-		syscall.StringToUTF16Ptr(pipeName),
-		windows.PIPE_ACCESS_DUPLEX|windows.FILE_FLAG_OVERLAPPED,
-		windows.PIPE_TYPE_MESSAGE|windows.PIPE_READMODE_MESSAGE|windows.PIPE_WAIT,
-		windows.PIPE_UNLIMITED_INSTANCES, //
-		1024,                             // Output buffer size
-		1024,                             // Input buffer size
-		0,                                // Default timeout
-		nil,                              // Security attributes
-	)
-	if err != nil {
-		return handle, err
-	}
-	return handle, nil
-}
-
-func handleClientRead(handle windows.Handle, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
+func handleClientRead(server pipeServer, pipeName string, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer cancel()
 	for {
@@ -36,15 +16,17 @@ func handleClientRead(handle windows.Handle, clientID int, ctx context.Context,
 			return
 
 		default:
-			dataRead, err := readFromHandle(handle, false)
+			_, dataRead, err := readFromConn(server)
 			if err != nil {
-				fmt.Printf("💧 %s 🔴 [%03d] Can't read (%v) \n", timeFormat(time.Now()), clientID, err)
+				event := clientEvent(pipeErrorEvent(pipeName, err), clientID)
+				printEvent(event, "💧 %s 🔴 [%03d] Can't read (%v) \n", timeFormat(event.Time), clientID, err)
 				return
 			}
 
 			dataLen := len(dataRead)
 			if dataLen > 0 {
-				fmt.Printf("💧 %s 🟢 [%03d] Received %d bytes: %q\n", timeFormat(time.Now()), clientID, len(dataRead), dataRead)
+				event := clientEvent(pipeDataEvent(pipeName, "received", dataRead), clientID)
+				printEvent(event, "💧 %s 🟢 [%03d] Received %d bytes: %q\n", timeFormat(event.Time), clientID, len(dataRead), dataRead)
 			} else {
 				select {
 				case <-ctx.Done():
@@ -57,7 +39,7 @@ func handleClientRead(handle windows.Handle, clientID int, ctx context.Context,
 	}
 }
 
-func handleClientWrite(handle windows.Handle, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
+func handleClientWrite(server pipeServer, pipeName string, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer cancel()
 	for {
@@ -67,12 +49,14 @@ func handleClientWrite(handle windows.Handle, clientID int, ctx context.Context,
 
 		default:
 			dataWrite := fmt.Sprintf("Hello from pipe %d !\n", clientID)
-			err := writeToHandle(handle, []byte(dataWrite), true)
+			_, err := server.Write([]byte(dataWrite))
 			if err != nil {
-				fmt.Printf("💧 %s 🔴 [%03d] Can't write (%v) \n", timeFormat(time.Now()), clientID, err)
+				event := clientEvent(pipeErrorEvent(pipeName, err), clientID)
+				printEvent(event, "💧 %s 🔴 [%03d] Can't write (%v) \n", timeFormat(event.Time), clientID, err)
 				return
 			}
-			fmt.Printf("💧 %s 🟠 [%03d] Sent hello message \n", timeFormat(time.Now()), clientID)
+			event := clientEvent(pipeDataEvent(pipeName, "sent", []byte(dataWrite)), clientID)
+			printEvent(event, "💧 %s 🟠 [%03d] Sent hello message \n", timeFormat(event.Time), clientID)
 			select {
 			case <-ctx.Done():
 				return
@@ -83,60 +67,89 @@ func handleClientWrite(handle windows.Handle, clientID int, ctx context.Context,
 	}
 }
 
-func handleClient(handle windows.Handle, clientID int) {
-	defer windows.CloseHandle(handle)
-	fmt.Printf("💧 %s ⚪ [%03d] Connected client \n", timeFormat(time.Now()), clientID)
+func handleClient(ctx context.Context, server pipeServer, pipeName string, clientID int) {
+	defer server.Close()
+	event := clientEvent(pipeEvent(pipeName, "connected"), clientID)
+	printEvent(event, "💧 %s ⚪ [%03d] Connected client \n", timeFormat(event.Time), clientID)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	var wg sync.WaitGroup
 
+	// Pending reads end on shutdown
+	stopCancel := context.AfterFunc(ctx, func() {
+		server.Close()
+	})
+	defer stopCancel()
+
 	// wg.Add(2)
 	wg.Add(1)
 
 	// Start reader
-	go handleClientRead(handle, clientID, ctx, cancel, &wg)
+	go handleClientRead(server, pipeName, clientID, ctx, cancel, &wg)
 
 	// Start writer
-	// go handleClientWrite(handle, clientID, ctx, cancel, &wg)
+	// go handleClientWrite(server, pipeName, clientID, ctx, cancel, &wg)
 
 	wg.Wait()
 
-	fmt.Printf("💧 %s ❌ [%03d] End client \n", timeFormat(time.Now()), clientID)
+	event = clientEvent(pipeEvent(pipeName, "disconnected"), clientID)
+	printEvent(event, "💧 %s ❌ [%03d] End client \n", timeFormat(event.Time), clientID)
 }
 
-func startWorker(pipeName string, clientID *int) {
-	for {
+func startWorker(ctx context.Context, pipeName string, clientID *int, clients *sync.WaitGroup) {
+	for ctx.Err() == nil {
 		var thisID int
 		thisID = *clientID
 		*clientID++
-		handle, err := createDuplexPipe(pipeName)
+		server, err := osPipes.create(pipeName)
 		if err != nil {
-			fmt.Printf("💧 %s 🔴 [%03d] Failed to start worker (%v)\n", timeFormat(time.Now()), thisID, err)
-			windows.CloseHandle(handle)
+			event := clientEvent(pipeErrorEvent(pipeName, err), thisID)
+			printEvent(event, "💧 %s 🔴 [%03d] Failed to start worker (%v)\n", timeFormat(event.Time), thisID, err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
 		// fmt.Printf("💧 %s ⚪ [%03d] Started pipe \n", timeFormat(time.Now()), thisID)
 
-		// Wait for a client to connect
-		err = windows.ConnectNamedPipe(handle, nil)
+		// Wait for a client to connect, closing the pipe on shutdown ends the wait
+		err = server.Connect(ctx)
+		if ctx.Err() != nil {
+			server.Close()
+			return
+		}
 		if err != nil {
-			fmt.Printf("💧 %s 🔴 [%03d] Client failed to connect to pipe (%v)\n", timeFormat(time.Now()), thisID, err)
-			windows.CloseHandle(handle)
+			event := clientEvent(pipeErrorEvent(pipeName, err), thisID)
+			printEvent(event, "💧 %s 🔴 [%03d] Client failed to connect to pipe (%v)\n", timeFormat(event.Time), thisID, err)
+			server.Close()
 		} else {
-			go handleClient(handle, thisID)
+			clients.Add(1)
+			go func() {
+				defer clients.Done()
+				handleClient(ctx, server, pipeName, thisID)
+			}()
 		}
 	}
 }
 
-func startServer(pipeName string, workers int) {
-	fmt.Printf("💧 %s ⚪ Pipe server %s (%d workers) \n", timeFormat(time.Now()), pipeName, workers)
+func startServer(ctx context.Context, pipeName string, workers int) {
+	event := pipeEvent(pipeName, "listening")
+	printEvent(event, "💧 %s ⚪ Pipe server %s (%d workers) \n", timeFormat(event.Time), pipeName, workers)
 	clientID := 0
+	var workersDone, clients sync.WaitGroup
 	for range workers {
-		go startWorker(pipeName, &clientID)
+		workersDone.Add(1)
+		go func() {
+			defer workersDone.Done()
+			startWorker(ctx, pipeName, &clientID, &clients)
+		}()
 		// time.Sleep(10 * time.Millisecond)
 	}
-	fmt.Printf("💧 %s ⚪ All workers are running \n", timeFormat(time.Now()))
-	select {}
+	if !jsonOutput {
+		printNotice("💧 %s ⚪ All workers are running \n", timeFormat(time.Now()))
+	}
+
+	// Until shutdown, then let clients say goodbye
+	<-ctx.Done()
+	workersDone.Wait()
+	clients.Wait()
 }
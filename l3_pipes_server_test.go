@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStartServer(t *testing.T) {
+	memory := newMemoryPipes()
+	usePipes(t, memory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := captureStdout(t, func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			startServer(ctx, `\\.\pipe\svc`, 1)
+		}()
+
+		memory.waitInstances(t, `\\.\pipe\svc`, 1)
+		client, err := memory.open(`\\.\pipe\svc`, 0)
+		if err != nil {
+			t.Error(err)
+		} else {
+			client.WriteMessage([]byte("hello"))
+			client.Close()
+		}
+		// The worker is waiting for the next client once the first one is handled
+		memory.waitInstances(t, `\\.\pipe\svc`, 1)
+		cancel()
+		<-done
+	})
+	for _, want := range []string{`⚪ Pipe server \\.\pipe\svc (1 workers)`, `⚪ [000] Connected client`, `🟢 [000] Received 5 bytes: "hello"`, `❌ [000] End client`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("missing %q in\n%s", want, output)
+		}
+	}
+}
@@ -2,41 +2,31 @@ package main
 
 import (
 	"bufio"
-	"fmt"
-	"net"
+	"context"
 	"os"
 	"time"
-
-	"github.com/Microsoft/go-winio"
 )
 
-func readFromConn(conn net.Conn) (int, []byte, error) {
-	var bufferSize int = 1024
-	var data []byte
-	for {
-		buffer := make([]byte, bufferSize)
-		n, err := conn.Read(buffer)
-		if err != nil {
-			return len(data), data, err
-		}
-		data = append(data, buffer[:n]...)
-		if n < bufferSize {
-			break
-		}
-	}
-
-	return len(data), data, nil
-}
-
-func chatWithPipe(pipeName string) {
-	conn, err := winio.DialPipe(pipeName, nil)
+func chatWithPipe(ctx context.Context, pipeName string) {
+	// Busy servers get 2 seconds to free an instance
+	dialCtx, cancelDial := context.WithTimeout(ctx, 2*time.Second)
+	conn, err := osPipes.dial(dialCtx, pipeName)
+	cancelDial()
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Can't connect (%v)\n", timeFormat(time.Now()), err)
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Can't connect (%v)\n", timeFormat(event.Time), err)
 		return
 	}
 	defer conn.Close()
 
-	fmt.Printf("💧 %s ⚪ Connected to %s\n", timeFormat(time.Now()), pipeName)
+	// Keyboard reads can't be interrupted, main stops waiting for us after a grace delay
+	stopClose := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stopClose()
+
+	event := pipeEvent(pipeName, "connected")
+	printEvent(event, "💧 %s ⚪ Connected to %s\n", timeFormat(event.Time), pipeName)
 
 	var input []rune
 	reader := bufio.NewReader(os.Stdin)
@@ -45,15 +35,20 @@ func chatWithPipe(pipeName string) {
 	go func() {
 		for {
 			dataLen, data, err := readFromConn(conn)
+			if ctx.Err() != nil {
+				return
+			}
 			if err != nil {
-				fmt.Printf("\n💧 %s 🔴 Can't read (%v)\n", timeFormat(time.Now()), err)
+				event := pipeErrorEvent(pipeName, err)
+				printEvent(event, "\n💧 %s 🔴 Can't read (%v)\n", timeFormat(event.Time), err)
 				return
 			}
 
 			if dataLen > 0 {
 				// Print the data read from the named pipe
-				fmt.Printf("\n💧 %s 🟢 received %d bytes : %q", timeFormat(time.Now()), dataLen, data)
-				fmt.Printf("\n💧 >>")
+				event := pipeDataEvent(pipeName, "received", data)
+				printEvent(event, "\n💧 %s 🟢 received %d bytes : %q", timeFormat(event.Time), dataLen, data)
+				printNotice("\n💧 >>")
 			} else {
 				time.Sleep(300 * time.Millisecond)
 			}
@@ -61,11 +56,11 @@ func chatWithPipe(pipeName string) {
 	}()
 
 	for {
-		fmt.Printf("\n💧 >> ")
+		printNotice("\n💧 >> ")
 		for {
 			r, _, err := reader.ReadRune()
 			if err != nil {
-				fmt.Printf("\n💧 %s 🔴 Error reading keyboard input (%v) \n", timeFormat(time.Now()), err)
+				printNotice("\n💧 %s 🔴 Error reading keyboard input (%v) \n", timeFormat(time.Now()), err)
 				return
 			}
 			if r == '\n' || r == '\r' { // Detect Enter key
@@ -73,7 +68,7 @@ func chatWithPipe(pipeName string) {
 					continue
 				}
 				if len(input) == 0 {
-					fmt.Printf("💧 >> ")
+					printNotice("💧 >> ")
 					continue
 				} else {
 					break
@@ -85,11 +80,13 @@ func chatWithPipe(pipeName string) {
 		data := []byte(string(input))
 		_, err := writer.Write(data) // _, err = conn.Write(data)
 		if err != nil {
-			fmt.Printf("💧 %s 🔴 Can't send data (%v) \n", timeFormat(time.Now()), err)
+			event := pipeErrorEvent(pipeName, err)
+			printEvent(event, "💧 %s 🔴 Can't send data (%v) \n", timeFormat(event.Time), err)
 			return
 		}
 		writer.Flush()
-		fmt.Printf("💧 %s 🟠 Sent %q", timeFormat(time.Now()), data)
+		event := pipeDataEvent(pipeName, "sent", data)
+		printEvent(event, "💧 %s 🟠 Sent %q", timeFormat(event.Time), data)
 		input = []rune{}
 	}
 }
@@ -8,11 +8,9 @@ import (
 	"time"
 
 	"net"
-
-	"github.com/Microsoft/go-winio"
 )
 
-func handleClientRead2(conn net.Conn, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
+func handleClientRead2(conn net.Conn, pipeName string, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer cancel()
 	for {
@@ -23,12 +21,14 @@ func handleClientRead2(conn net.Conn, clientID int, ctx context.Context, cancel
 		default:
 			dataLen, data, err := readFromConn(conn)
 			if err != nil {
-				fmt.Printf("💧 %s 🔴 [%03d] Can't read (%v) \n", timeFormat(time.Now()), clientID, err)
+				event := clientEvent(pipeErrorEvent(pipeName, err), clientID)
+				printEvent(event, "💧 %s 🔴 [%03d] Can't read (%v) \n", timeFormat(event.Time), clientID, err)
 				return
 			}
 
 			if dataLen > 0 {
-				fmt.Printf("💧 %s 🟢 [%03d] Received %d bytes %q\n", timeFormat(time.Now()), clientID, dataLen, data)
+				event := clientEvent(pipeDataEvent(pipeName, "received", data), clientID)
+				printEvent(event, "💧 %s 🟢 [%03d] Received %d bytes %q\n", timeFormat(event.Time), clientID, dataLen, data)
 			} else {
 				select {
 				case <-ctx.Done():
@@ -41,7 +41,7 @@ func handleClientRead2(conn net.Conn, clientID int, ctx context.Context, cancel
 	}
 }
 
-func handleClientWrite2(conn net.Conn, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
+func handleClientWrite2(conn net.Conn, pipeName string, clientID int, ctx context.Context, cancel context.CancelFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer cancel()
 	writer := bufio.NewWriter(conn)
@@ -55,11 +55,13 @@ func handleClientWrite2(conn net.Conn, clientID int, ctx context.Context, cancel
 			data := fmt.Sprintf("Hello from pipe %d !\n", clientID)
 			_, err := writer.Write([]byte(data)) //_, err := conn.Write([]byte(data))
 			if err != nil {
-				fmt.Printf("💧 %s 🔴 [%03d] Can't write (%v) \n", timeFormat(time.Now()), clientID, err)
+				event := clientEvent(pipeErrorEvent(pipeName, err), clientID)
+				printEvent(event, "💧 %s 🔴 [%03d] Can't write (%v) \n", timeFormat(event.Time), clientID, err)
 				return
 			}
 			writer.Flush()
-			fmt.Printf("💧 %s 🟠 [%03d] Sent hello message \n", timeFormat(time.Now()), clientID)
+			event := clientEvent(pipeDataEvent(pipeName, "sent", []byte(data)), clientID)
+			printEvent(event, "💧 %s 🟠 [%03d] Sent hello message \n", timeFormat(event.Time), clientID)
 			select {
 			case <-ctx.Done():
 				return
@@ -70,42 +72,71 @@ func handleClientWrite2(conn net.Conn, clientID int, ctx context.Context, cancel
 	}
 }
 
-func handleClient2(conn net.Conn, clientID int) {
-	fmt.Printf("💧 %s ⚪ [%03d] Connected client \n", timeFormat(time.Now()), clientID)
+func handleClient2(ctx context.Context, conn net.Conn, pipeName string, clientID int) {
+	event := clientEvent(pipeEvent(pipeName, "connected"), clientID)
+	printEvent(event, "💧 %s ⚪ [%03d] Connected client \n", timeFormat(event.Time), clientID)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	var wg sync.WaitGroup
 
+	// Closing the connection ends a pending read
+	stopClose := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stopClose()
+
 	wg.Add(2)
 
 	// Start reader
-	go handleClientRead2(conn, clientID, ctx, cancel, &wg)
+	go handleClientRead2(conn, pipeName, clientID, ctx, cancel, &wg)
 
 	// Start writer
-	go handleClientWrite2(conn, clientID, ctx, cancel, &wg)
+	go handleClientWrite2(conn, pipeName, clientID, ctx, cancel, &wg)
 
 	wg.Wait()
 
 	conn.Close()
-	fmt.Printf("💧 %s ❌ [%03d] Connection closed \n", timeFormat(time.Now()), clientID)
+	event = clientEvent(pipeEvent(pipeName, "disconnected"), clientID)
+	printEvent(event, "💧 %s ❌ [%03d] Connection closed \n", timeFormat(event.Time), clientID)
 }
 
-func startServer2(pipeName string) {
-	listener, err := winio.ListenPipe(pipeName, nil)
+func startServer2(ctx context.Context, pipeName string) {
+	listener, err := osPipes.listen(pipeName)
 	if err != nil {
-		fmt.Printf("💧 %s 🔴 Failed to start server (%v)\n", timeFormat(time.Now()), err)
+		event := pipeErrorEvent(pipeName, err)
+		printEvent(event, "💧 %s 🔴 Failed to start server (%v)\n", timeFormat(event.Time), err)
 		return
 	}
-	fmt.Printf("💧 %s ⚪ Started server %s\n", timeFormat(time.Now()), pipeName)
+	event := pipeEvent(pipeName, "listening")
+	printEvent(event, "💧 %s ⚪ Started server %s\n", timeFormat(event.Time), pipeName)
 	clientID := 0
 
+	// Closing the listener ends Accept and frees the pipe name
+	context.AfterFunc(ctx, func() {
+		listener.Close()
+	})
+
+	var clients sync.WaitGroup
+	defer clients.Wait()
+
 	for {
 		conn, err := listener.Accept()
+		if ctx.Err() != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
 		if err != nil {
-			fmt.Printf("💧 %s 🔴 Failed to handle new client (%v)\n", timeFormat(time.Now()), err)
+			event := clientEvent(pipeErrorEvent(pipeName, err), clientID)
+			printEvent(event, "💧 %s 🔴 Failed to handle new client (%v)\n", timeFormat(event.Time), err)
 			continue
 		}
-		go handleClient2(conn, clientID) // Handle each client in a new goroutine
+		clients.Add(1)
+		go func(clientID int) {
+			defer clients.Done()
+			handleClient2(ctx, conn, pipeName, clientID) // Handle each client in a new goroutine
+		}(clientID)
 		clientID++
 	}
 }
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
+	"time"
 )
 
 var debug bool
+
 var helpmsg string = `
  Usage:
 
@@ -15,21 +21,165 @@ var helpmsg string = `
 
     -files
         Files only 📁
+        Privesc paths are flagged 🚩 (when not running elevated)
 
     -pipes
         Named pipes only 💧
+        🐧 Unix sockets and FIFOs
 	
     -hijack int
         Try to start an instance for each pipe 💧
         1: Check only 
         2: Start MiTM
 
+    -path string
+        Directory to watch instead of all drives (repeatable)
+        Local, UNC or mounted volume paths, with options:
+        "C:\Program Files|depth=2"  watch 2 levels below
+        "D:\Drop|flat"              direct entries only
+
+    -pathfile file
+        Directories to watch, one -path per line (# comments)
+
+    -fanotify
+        🐧 Use fanotify instead of inotify (root required)
+        Shows the process behind each open 🟡, modify 🟠 and close-write 🟤
+
+    -buffer int
+        Watcher buffer size in bytes (default 4096)
+        Windows network shares are limited to 65536
+
+    -rescan
+        Keep a listing of watched trees, and rescan them
+        when an overflow ⚠️ drops events (recovered ♻️)
+
+    -hash list
+        Hash added and modified files, comma separated
+        sha256,md5,sha1
+
+    -hashmax int
+        Skip files bigger than this, in bytes (default 67108864)
+
+    -hashworkers int
+        Concurrent hashes (default 4)
+
+    -enrichworkers int
+        Concurrent event enrichments: access, owner, ACL,
+        hashes ... (default 16)
+
+    -enrichqueue int
+        Events waiting for enrichment (default 4096)
+
+    -overload policy
+        When the enrichment queue is full (default block)
+        block: wait, the watcher slows down and may overflow ⚠️
+        drop: report the event without enrichment ⏭️
+        Counters are shown with -stats
+
+    -capture dir
+        Copy added and modified files matching -capturematch
+        into dir 📥, with a .json metadata sidecar
+
+    -capturematch pattern
+        Files to capture (repeatable), same syntax as -include
+        Default: scripts and binaries (*.ps1, *.bat, *.exe, *.dll, *.sh ...)
+
+    -capturemax int
+        Skip files bigger than this, in bytes (default 16777216)
+
+    -acl
+        Show the ACL below each event (and with -check),
+        one line per ACE: allow ✅ / deny ⛔, trustee,
+        inheritance flags and decoded rights
+        Windows DACL, POSIX ACL or mode bits on Linux
+
+    -probe
+        Try each specific right on its own (events and -check),
+        show granted ones 🔑, critical ones flagged 🚩 (write DAC,
+        write owner, add file, create instance ...) and the mask
+        Linux: read, write, execute, chmod, chown, delete (access(2))
+
+    -stats int
+        Every N seconds and on exit, print a summary 📊
+        Counts per action, new and removed pipes,
+        top directories and most changed files
+
+    -lookuptimeout int
+        Milliseconds to wait for an account name (default 1000)
+        Slower lookups (unreachable domain) print the raw SID
+        or uid, names are cached, failures retried after 10 min
+
+    -showids
+        Print account names with their SID or uid,
+        e.g. NT AUTHORITY\SYSTEM (S-1-5-18)
+
+    -json
+        Print one JSON object per event (JSON Lines)
+
+----------------------------------------------
+
+ 📸 Snapshot
+
+    -snapshot file [roots...]
+        Save path, size, mtime, attributes, owner and access
+        of roots (-path or default roots when none) to file
+
+    -diff file
+        Compare the saved roots with the snapshot file,
+        and show New 🟢, Delete ❌ and Modify 🟠
+
+    -sddl string
+        Decode an SDDL security descriptor, offline and on any OS
+        e.g. -sddl "O:BAG:SYD:PAI(A;OICI;FA;;;SY)(A;;0x1200a9;;;BU)"
+
+----------------------------------------------
+
+ 🔎 Filters (monitoring and -listpipes)
+
+    -include pattern
+        Only show paths matching pattern (repeatable)
+
+    -exclude pattern
+        Hide paths matching pattern (repeatable)
+        Patterns are globs (* and ?) on the full path,
+        or regex with "re:" prefix
+
+    -action list
+        Comma separated actions to show
+        added,removed,modified,renamed,moved_out,moved_in,existing,
+        opened,closed_write (fanotify)
+
+    -kind list
+        Comma separated kinds to show (file,dir,pipe)
+
+    -owner pattern
+        Only show entries whose owner matches (repeatable)
+        ex: SYSTEM, "BUILTIN\Administrators", "re:(?i)admin"
+
+    -access list
+        Comma separated access to show (RW,R-,-W,--)
+
+    -profile name
+        Load noise filters before the ones above
+        quiet: hide OS noise (temp, prefetch, browser caches,
+               Defender, NTUSER logs) and fanotify opens
+        privesc: quiet, minus logs, only new content
+               (added,modified,renamed,moved_in,existing)
+        An explicit -action replaces the profile one
+
+    -profilefile file
+        Extend or create profiles, ex:
+        [quiet]
+        exclude = C:\MyApp\cache\*
+        action = added,modified
+
 ----------------------------------------------
 
  💧 Pipe Client
 
     -listpipes
         List pipes and quit
+        🐧 Unix sockets and FIFOs, -check shows their process
 
     -pipe string
         Pipe path
@@ -83,16 +233,34 @@ var version string = `
 
 var hijack int
 
-func main() {
-	fmt.Printf("%s", version)
-	defer fmt.Printf("\n")
+// Watchers buffer size in bytes (-buffer)
+var bufferSize int
 
+// Keep a listing of watched trees and rescan them after an overflow (-rescan)
+var rescanOnOverflow bool
+
+func main() {
 	var usage string
 	var files bool
 	var pipes bool
 	flag.BoolVar(&pipes, "pipes", false, usage)
 	flag.BoolVar(&files, "files", false, usage)
 
+	var fanotify bool
+	flag.BoolVar(&fanotify, "fanotify", false, usage)
+
+	var paths stringList
+	flag.Var(&paths, "path", usage)
+
+	var pathFile string
+	flag.StringVar(&pathFile, "pathfile", "", usage)
+
+	// var bufferSize int
+	flag.IntVar(&bufferSize, "buffer", 4096, usage)
+
+	// var rescanOnOverflow bool
+	flag.BoolVar(&rescanOnOverflow, "rescan", false, usage)
+
 	var listpipes bool
 	flag.BoolVar(&listpipes, "listpipes", false, usage)
 
@@ -102,9 +270,38 @@ func main() {
 	var check bool
 	flag.BoolVar(&check, "check", false, usage)
 
+	// var aclDump bool
+	flag.BoolVar(&aclDump, "acl", false, usage)
+
+	// var accessProbe bool
+	flag.BoolVar(&accessProbe, "probe", false, usage)
+
 	// var debug bool
 	flag.BoolVar(&debug, "debug", false, usage)
 
+	// var jsonOutput bool
+	flag.BoolVar(&jsonOutput, "json", false, usage)
+
+	var lookupTimeout int
+	flag.IntVar(&lookupTimeout, "lookuptimeout", 1000, usage)
+
+	// var accounts.both bool
+	flag.BoolVar(&accounts.both, "showids", false, usage)
+
+	var include, exclude, owners stringList
+	flag.Var(&include, "include", usage)
+	flag.Var(&exclude, "exclude", usage)
+	flag.Var(&owners, "owner", usage)
+
+	var actions, kinds, access string
+	flag.StringVar(&actions, "action", "", usage)
+	flag.StringVar(&kinds, "kind", "", usage)
+	flag.StringVar(&access, "access", "", usage)
+
+	var profile, profileFile string
+	flag.StringVar(&profile, "profile", "", usage)
+	flag.StringVar(&profileFile, "profilefile", "", usage)
+
 	var bytes bool
 	flag.BoolVar(&bytes, "bytes", false, usage)
 
@@ -135,19 +332,114 @@ func main() {
 	var exhaust int
 	flag.IntVar(&exhaust, "exhaust", 0, usage)
 
+	var hashList string
+	flag.StringVar(&hashList, "hash", "", usage)
+
+	var hashMax int64
+	flag.Int64Var(&hashMax, "hashmax", 64<<20, usage)
+
+	var hashWorkers int
+	flag.IntVar(&hashWorkers, "hashworkers", 4, usage)
+
+	var enrichWorkers, enrichQueue int
+	flag.IntVar(&enrichWorkers, "enrichworkers", 16, usage)
+	flag.IntVar(&enrichQueue, "enrichqueue", 4096, usage)
+
+	var overload string
+	flag.StringVar(&overload, "overload", "block", usage)
+
+	var captureDir string
+	flag.StringVar(&captureDir, "capture", "", usage)
+
+	var captureMatch stringList
+	flag.Var(&captureMatch, "capturematch", usage)
+
+	var captureMax int64
+	flag.Int64Var(&captureMax, "capturemax", 16<<20, usage)
+
+	var statsInterval int
+	flag.IntVar(&statsInterval, "stats", 0, usage)
+
+	var snapshotFile string
+	flag.StringVar(&snapshotFile, "snapshot", "", usage)
+
+	var diffFile string
+	flag.StringVar(&diffFile, "diff", "", usage)
+
+	var sddlString string
+	flag.StringVar(&sddlString, "sddl", "", usage)
+
 	var help bool
 	flag.BoolVar(&help, "help", false, usage)
 	flag.BoolVar(&help, "h", false, usage)
 
 	flag.Parse()
 
+	// Keep stdout clean for JSON consumers
+	if jsonOutput {
+		fmt.Fprintf(os.Stderr, "%s", version)
+	} else {
+		fmt.Printf("%s", version)
+		defer fmt.Printf("\n")
+	}
+
 	if help {
-		fmt.Printf(helpmsg)
+		fmt.Print(helpmsg)
+		return
+	}
+
+	var err error
+	if profile != "" {
+		err = applyProfile(profile, profileFile, &include, &exclude, &actions)
+		if err != nil {
+			printNotice("[*] Profile error: %v\n", err)
+			return
+		}
+	} else if profileFile != "" {
+		printNotice("[*] -profilefile needs -profile\n")
+		return
+	}
+
+	filter, err = newEventFilter(include, exclude, owners, actions, kinds, access)
+	if err != nil {
+		printNotice("[*] Filter error: %v\n", err)
+		return
+	}
+
+	roots, err := parseRoots(paths, pathFile)
+	if err != nil {
+		printNotice("[*] Path error: %v\n", err)
 		return
 	}
 
-	// exit channel for interactive modes
-	isexit := make(chan bool)
+	if hashList != "" {
+		hasher, err = newHashPool(hashList, hashMax, hashWorkers)
+		if err != nil {
+			printNotice("[*] Hash error: %v\n", err)
+			return
+		}
+	}
+
+	accounts.Timeout = time.Duration(lookupTimeout) * time.Millisecond
+
+	enricher, err = newEnrichPool(enrichWorkers, enrichQueue, overload)
+	if err != nil {
+		printNotice("[*] Enrichment error: %v\n", err)
+		return
+	}
+
+	if captureDir != "" {
+		capturer, err = newCaptureConfig(captureDir, captureMatch, captureMax)
+		if err != nil {
+			printNotice("[*] Capture error: %v\n", err)
+			return
+		}
+	}
+
+	// Ctrl+C and SIGTERM cancel ctx, modes close their handles and return
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	appContext = ctx
 
 	// SERVER MODE ///////////////////////
 
@@ -155,7 +447,7 @@ func main() {
 		if pipe == "" {
 			pipe = `\\.\pipe\testing`
 		}
-		startServer2(pipe)
+		runPipeMode(ctx, pipe, func(ctx context.Context) { startServer2(ctx, pipe) })
 		return
 	}
 
@@ -163,7 +455,7 @@ func main() {
 		if pipe == "" {
 			pipe = `\\.\pipe\testing`
 		}
-		startServer(pipe, workers)
+		runPipeMode(ctx, pipe, func(ctx context.Context) { startServer(ctx, pipe, workers) })
 		return
 	}
 
@@ -172,90 +464,107 @@ func main() {
 	missingpipe := "[*] Missing pipe argument \n"
 
 	if check && pipe != "" {
-		go checkPipe(pipe, isexit)
-		//go waitForExitInput(isexit)
-		<-isexit
+		runMode(ctx, func(ctx context.Context) { checkPipe(ctx, pipe) })
 		return
 	}
 
 	if exhaust > 0 {
 		if pipe == "" {
-			fmt.Printf(missingpipe)
+			printNotice("%s", missingpipe)
 			return
 		}
-		go waitForExitInput(isexit)
-		go exhaustPipe(pipe, exhaust)
-		<-isexit
+		ctx, cancel := context.WithCancel(ctx)
+		go waitForExitInput(cancel)
+		runPipeMode(ctx, pipe, func(ctx context.Context) { exhaustPipe(ctx, pipe, exhaust) })
 		return
 	}
 
 	if write != "" {
 		if pipe == "" {
-			fmt.Printf(missingpipe)
+			printNotice("%s", missingpipe)
 			return
 		}
 		if bytes {
 			interpretedStr, err := strconv.Unquote(`"` + write + `"`)
 			if err != nil {
-				fmt.Println("Error interpreting escape sequences:", err)
+				printNotice("Error interpreting escape sequences: %v\n", err)
 				return
 			}
 			write = interpretedStr
 		}
-		go writeToPipe(pipe, []byte(write), isexit)
-		//go waitForExitInput(isexit)
-		<-isexit
+		runPipeMode(ctx, pipe, func(ctx context.Context) { writeToPipe(ctx, pipe, []byte(write)) })
 		return
 	}
 
 	if writeread != "" {
 		if pipe == "" {
-			fmt.Printf(missingpipe)
+			printNotice("%s", missingpipe)
 			return
 		}
 		if bytes {
 			interpretedStr, err := strconv.Unquote(`"` + writeread + `"`)
 			if err != nil {
-				fmt.Println("Error interpreting escape sequences:", err)
+				printNotice("Error interpreting escape sequences: %v\n", err)
 				return
 			}
 			writeread = interpretedStr
 		}
-		go writeReadToPipe(pipe, []byte(writeread), isexit)
-		go waitForExitInput(isexit)
-		<-isexit
+		ctx, cancel := context.WithCancel(ctx)
+		go waitForExitInput(cancel)
+		runPipeMode(ctx, pipe, func(ctx context.Context) { writeReadToPipe(ctx, pipe, []byte(writeread)) })
 		return
 	}
 
 	if read {
 		if pipe == "" {
-			fmt.Printf(missingpipe)
+			printNotice("%s", missingpipe)
 			return
 		}
-		go readFromPipe(pipe, isexit)
-		go waitForExitInput(isexit)
-		<-isexit
+		ctx, cancel := context.WithCancel(ctx)
+		go waitForExitInput(cancel)
+		runPipeMode(ctx, pipe, func(ctx context.Context) { readFromPipe(ctx, pipe) })
 		return
 	}
 
 	if chat {
 		if pipe == "" {
-			fmt.Printf(missingpipe)
+			printNotice("%s", missingpipe)
 			return
 		}
-		chatWithPipe(pipe)
+		runPipeMode(ctx, pipe, func(ctx context.Context) { chatWithPipe(ctx, pipe) })
 		return
 	}
 
 	if pipe != "" {
-		fmt.Printf("[*] Missing action for pipe \n")
+		printNotice("[*] Missing action for pipe \n")
+		return
+	}
+
+	// SNAPSHOT MODES ////////////////////////////
+
+	if snapshotFile != "" {
+		snapshotRoots := flag.Args()
+		if len(snapshotRoots) == 0 {
+			snapshotRoots = rootPaths(roots)
+		}
+		saveSnapshot(snapshotFile, snapshotRoots)
+		return
+	}
+
+	if diffFile != "" {
+		diffSnapshot(diffFile)
+		return
+	}
+
+	if sddlString != "" {
+		printSddl(sddlString)
 		return
 	}
 
 	// NORMAL MODES ////////////////////////////
 
 	if !pipes && !files {
-		pipes = true
+		pipes = pipesListed
 		files = true
 	}
 
@@ -263,23 +572,48 @@ func main() {
 		files = false
 	}
 
-	if pipes {
+	// Summary every N seconds, and on exit
+	if statsInterval > 0 {
+		stats = newEventStats()
+		go stats.run(time.Duration(statsInterval) * time.Second)
+		defer stats.print("Final summary")
+	}
+
+	// Listing pipes stops once done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var monitors sync.WaitGroup
+	startMonitor := func(monitor func()) {
+		monitors.Add(1)
 		go func() {
-			monitornamedpipes(check, listpipes)
-			isexit <- true
+			defer monitors.Done()
+			monitor()
 		}()
 	}
 
-	if files {
-		for driveLetter := 'C'; driveLetter <= 'Z'; driveLetter++ {
-			go func() {
-				drivePath := fmt.Sprintf("%c:\\", driveLetter)
-				if _, err := os.Stat(drivePath); err == nil {
-					go monitorpath(drivePath, 0)
-				}
-			}()
+	if pipes {
+		startMonitor(func() {
+			monitornamedpipes(ctx, check, listpipes)
+			cancel()
+		})
+	}
+
+	if files && fanotify {
+		startMonitor(func() { monitorfanotify(ctx, roots, 0) })
+	} else if files {
+		for _, root := range roots {
+			startMonitor(func() { monitorpath(ctx, root, 0) })
+		}
+		// Default roots are the drives, watch the ones plugged in later
+		if len(paths) == 0 && pathFile == "" {
+			go followDrives(ctx, 0, 5*time.Second)
 		}
 	}
 
-	<-isexit
+	<-ctx.Done()
+
+	// Watchers close their handles, then events being enriched are printed
+	waitGrace(monitors.Wait)
+	waitGrace(enricher.wait)
 }
@@ -0,0 +1,37 @@
+// Package pipes opens, creates, dials and inspects Windows named pipes.
+//
+// Every function returns results instead of printing them. Other systems
+// get ErrUnsupported, Supported tells which one is built.
+package pipes
+
+import (
+	"errors"
+)
+
+// Directory listing every named pipe
+const Root = `\\.\pipe\`
+
+var ErrUnsupported = errors.New("named pipes are only supported on Windows")
+
+// Access asked by Open
+type Access uint32
+
+const (
+	Read    Access = 0x80000000 // GENERIC_READ
+	Write   Access = 0x40000000 // GENERIC_WRITE
+	Control Access = 0x00020000 // READ_CONTROL, enough for the queries of Check
+)
+
+// Info is what Check learned about a pipe, through the best handle it could open
+type Info struct {
+	Read       bool
+	Write      bool
+	Control    bool   // READ_CONTROL, the fields below need it
+	Access     string // RW, R-, -W or --
+	Pid        uint32 // server process
+	Owner      string // SID
+	Descriptor string // SDDL, owner and DACL
+	State      string // WAIT, NOWAIT, MESSAGE, empty when unknown
+	Instances  uint32
+	HasState   bool
+}
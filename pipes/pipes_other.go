@@ -0,0 +1,68 @@
+//go:build !windows
+
+package pipes
+
+import (
+	"context"
+	"net"
+)
+
+const Supported = false
+
+// Client is our end of a pipe opened with Open
+type Client struct{}
+
+// Instance is a pipe instance created with Create
+type Instance struct{}
+
+func Open(name string, access Access) (*Client, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *Client) ReadMessage(ctx context.Context) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *Client) WriteMessage(data []byte) error {
+	return ErrUnsupported
+}
+
+func (c *Client) Close() error {
+	return nil
+}
+
+func Create(name string) (*Instance, error) {
+	return nil, ErrUnsupported
+}
+
+func (p *Instance) Connect(ctx context.Context) error {
+	return ErrUnsupported
+}
+
+func (p *Instance) Read(buffer []byte) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (p *Instance) Write(data []byte) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (p *Instance) Close() error {
+	return nil
+}
+
+func Dial(ctx context.Context, name string) (net.Conn, error) {
+	return nil, ErrUnsupported
+}
+
+func Listen(name string) (net.Listener, error) {
+	return nil, ErrUnsupported
+}
+
+func Check(name string) Info {
+	return Info{}
+}
+
+func List() ([]string, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,345 @@
+package pipes
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+const Supported = true
+
+var (
+	kernel32                    = windows.NewLazyDLL("kernel32.dll")
+	procReadFileEx              = kernel32.NewProc("ReadFileEx")
+	procWriteFileEx             = kernel32.NewProc("WriteFileEx")
+	procGetNamedPipeServerPID   = kernel32.NewProc("GetNamedPipeServerProcessId")
+	procGetNamedPipeHandleState = kernel32.NewProc("GetNamedPipeHandleStateW")
+)
+
+// Client is our end of a pipe opened with Open, reads and writes are synchronous
+type Client struct {
+	handle windows.Handle
+}
+
+// Open connects to name with access only, nothing else is tried
+func Open(name string, access Access) (*Client, error) {
+	handle, err := windows.CreateFile(
+		syscall.StringToUTF16Ptr(name),
+		uint32(access),
+		0,
+		nil,
+		windows.OPEN_EXISTING,
+		0,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{handle: handle}, nil
+}
+
+// ReadMessage returns the next message, chunk after chunk until a short one
+// A pending read only ends with data, an error or ctx cancellation
+func (c *Client) ReadMessage(ctx context.Context) ([]byte, error) {
+	stopCancel := context.AfterFunc(ctx, func() {
+		windows.CancelIoEx(c.handle, nil)
+	})
+	defer stopCancel()
+
+	var bufferSize uint32 = 1024
+	var bufferContentSize uint32
+	var data []byte
+	for {
+		buffer := make([]byte, bufferSize)
+		err := windows.ReadFile(c.handle, buffer, &bufferContentSize, nil)
+		if ctx.Err() != nil {
+			return data, ctx.Err()
+		}
+		if err != nil {
+			return data, err
+		}
+		data = append(data, buffer[:bufferContentSize]...)
+		if bufferContentSize < bufferSize {
+			return data, nil
+		}
+	}
+}
+
+// WriteMessage writes data and waits for the server to read it
+func (c *Client) WriteMessage(data []byte) error {
+	defer windows.FlushFileBuffers(c.handle)
+	var dataLen = len(data)
+	var totalWritten = 0
+	for totalWritten < dataLen {
+		var chunkWritten uint32
+		err := windows.WriteFile(c.handle, data[totalWritten:], &chunkWritten, nil)
+		if err != nil {
+			return err
+		}
+		totalWritten += int(chunkWritten)
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	return windows.CloseHandle(c.handle)
+}
+
+// Instance is a pipe instance created with Create
+// Reads and writes are alertable (ReadFileEx, WriteFileEx)
+type Instance struct {
+	handle    windows.Handle
+	closeOnce sync.Once
+}
+
+// Create adds a duplex message instance to name, squatting it when the server didn't create it first
+func Create(name string) (*Instance, error) {
+	handle, err := windows.CreateNamedPipe(
+		syscall.StringToUTF16Ptr(name),
+		windows.PIPE_ACCESS_DUPLEX|windows.FILE_FLAG_OVERLAPPED,
+		windows.PIPE_TYPE_MESSAGE|windows.PIPE_READMODE_MESSAGE|windows.PIPE_WAIT,
+		windows.PIPE_UNLIMITED_INSTANCES, //
+		1024,                             // Output buffer size
+		1024,                             // Input buffer size
+		0,                                // Default timeout
+		nil,                              // Security attributes
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Instance{handle: handle}, nil
+}
+
+// Connect waits for a client, cancelling ctx closes the instance
+// It returns nil when the client connected before the call too
+func (p *Instance) Connect(ctx context.Context) error {
+	stopClose := context.AfterFunc(ctx, func() {
+		p.Close()
+	})
+	err := windows.ConnectNamedPipe(p.handle, nil)
+	if !stopClose() {
+		return ctx.Err()
+	}
+	if err == windows.ERROR_PIPE_CONNECTED {
+		return nil
+	}
+	return err
+}
+
+// ioRequest gets the result of an alertable read or write from the completion routine
+type ioRequest struct {
+	overlapped windows.Overlapped
+	done       bool
+	errCode    uint32
+	bytes      uint32
+}
+
+// One routine for every request, callbacks are never released
+var ioCompletion = windows.NewCallback(func(errCode uint32, numBytes uint32, overlapped *windows.Overlapped) uintptr {
+	request := (*ioRequest)(unsafe.Pointer(overlapped))
+	request.errCode = errCode
+	request.bytes = numBytes
+	request.done = true
+	return 0
+})
+
+// alertable calls ReadFileEx or WriteFileEx and sleeps until the completion routine ran
+// The routine is queued to the calling thread, the goroutine must stay on it
+func (p *Instance) alertable(proc *windows.LazyProc, buffer []byte) (int, error) {
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	request := new(ioRequest)
+	ret, _, err := proc.Call(
+		uintptr(p.handle),
+		uintptr(unsafe.Pointer(&buffer[0])),
+		uintptr(len(buffer)),
+		uintptr(unsafe.Pointer(&request.overlapped)),
+		ioCompletion,
+	)
+	if ret == 0 {
+		return 0, err
+	}
+	for !request.done {
+		windows.SleepEx(windows.INFINITE, true)
+	}
+	runtime.KeepAlive(buffer)
+
+	// Longer messages come with ERROR_MORE_DATA, the rest is read next
+	if request.errCode != 0 && request.errCode != uint32(windows.ERROR_MORE_DATA) {
+		return int(request.bytes), syscall.Errno(request.errCode)
+	}
+	return int(request.bytes), nil
+}
+
+func (p *Instance) Read(buffer []byte) (int, error) {
+	return p.alertable(procReadFileEx, buffer)
+}
+
+func (p *Instance) Write(data []byte) (int, error) {
+	written, err := p.alertable(procWriteFileEx, data)
+	if err == nil && written < len(data) {
+		err = io.ErrShortWrite
+	}
+	return written, err
+}
+
+// Close ends pending reads and writes, it can be called again
+func (p *Instance) Close() error {
+	p.closeOnce.Do(func() {
+		windows.CancelIoEx(p.handle, nil)
+		windows.CloseHandle(p.handle)
+	})
+	return nil
+}
+
+// Dial connects to name as a client, waiting for a free instance until ctx is done
+func Dial(ctx context.Context, name string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, name)
+}
+
+// Listen serves name, closing the listener frees the name
+func Listen(name string) (net.Listener, error) {
+	return winio.ListenPipe(name, nil)
+}
+
+// List returns the full name of every pipe
+func List() ([]string, error) {
+	entries, err := os.ReadDir(Root)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, Root+entry.Name())
+	}
+	return names, nil
+}
+
+// Check keeps the best handle open while the queries run
+func Check(name string) Info {
+	var info Info
+	handle, readAccess, writeAccess, controlAccess, displayAccess := bestHandle(name)
+	defer windows.CloseHandle(handle)
+
+	info.Read = readAccess
+	info.Write = writeAccess
+	info.Control = controlAccess
+	info.Access = displayAccess
+	if !controlAccess {
+		return info
+	}
+
+	var wg sync.WaitGroup
+	var state, instances uint32
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		info.Pid = serverPid(handle)
+	}()
+	go func() {
+		defer wg.Done()
+		info.Owner, info.Descriptor = descriptor(handle)
+	}()
+	go func() {
+		defer wg.Done()
+		info.HasState = handleState(handle, &state, &instances)
+	}()
+	wg.Wait()
+
+	if info.HasState {
+		info.Instances = instances
+		switch state {
+		case uint32(0):
+			info.State = "WAIT"
+		case windows.PIPE_NOWAIT:
+			info.State = "NOWAIT"
+		case windows.PIPE_READMODE_MESSAGE:
+			info.State = "MESSAGE"
+		}
+	}
+	return info
+}
+
+// bestHandle tries read and write, read, write, then READ_CONTROL
+// Each try takes a pipe instance while it is open
+func bestHandle(name string) (windows.Handle, bool, bool, bool, string) {
+	tries := []struct {
+		access  uint32
+		read    bool
+		write   bool
+		control bool
+		display string
+	}{
+		{windows.GENERIC_READ | windows.GENERIC_WRITE, true, true, true, "RW"},
+		{windows.GENERIC_READ, true, false, false, "R-"},
+		{windows.GENERIC_WRITE, false, true, false, "-W"},
+		{windows.READ_CONTROL, false, false, true, "--"},
+	}
+	var handle windows.Handle
+	var err error
+	for _, try := range tries {
+		handle, err = windows.CreateFile(
+			windows.StringToUTF16Ptr(name),
+			try.access,
+			windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+			nil,
+			windows.OPEN_EXISTING,
+			0,
+			0,
+		)
+		if err == nil {
+			return handle, try.read, try.write, try.control, try.display
+		}
+	}
+	return handle, false, false, false, ""
+}
+
+func serverPid(handle windows.Handle) uint32 {
+	var pid uint32
+	ret, _, _ := procGetNamedPipeServerPID.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(&pid)),
+	)
+	if ret == 0 {
+		return 0
+	}
+	return pid
+}
+
+// descriptor returns the owner SID and the SDDL of owner and DACL
+func descriptor(handle windows.Handle) (string, string) {
+	sd, err := windows.GetSecurityInfo(handle, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return "", ""
+	}
+	var owner string
+	if sid, _, err := sd.Owner(); err == nil {
+		owner = sid.String()
+	}
+	return owner, sd.String()
+}
+
+func handleState(handle windows.Handle, state *uint32, instances *uint32) bool {
+	ret, _, _ := procGetNamedPipeHandleState.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(state)),
+		uintptr(unsafe.Pointer(instances)),
+		0,
+		0,
+		0,
+		0,
+	)
+	return ret != 0
+}
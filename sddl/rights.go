@@ -0,0 +1,314 @@
+package sddl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type namedValue struct {
+	code  string
+	value uint32
+}
+
+// ACE types, SDDL code and binary value
+var aceTypes = []namedValue{
+	{"A", 0x0},
+	{"D", 0x1},
+	{"AU", 0x2},
+	{"AL", 0x3},
+	{"OA", 0x5},
+	{"OD", 0x6},
+	{"OU", 0x7},
+	{"OL", 0x8},
+	{"XA", 0x9},
+	{"XD", 0xa},
+	{"ZA", 0xb},
+	{"XU", 0xd},
+	{"ML", 0x11},
+	{"RA", 0x12},
+	{"SP", 0x13},
+}
+
+var aceTypeNames = map[string]string{
+	"A":  "allow",
+	"D":  "deny",
+	"AU": "audit",
+	"AL": "alarm",
+	"OA": "allow object",
+	"OD": "deny object",
+	"OU": "audit object",
+	"OL": "alarm object",
+	"XA": "allow callback",
+	"XD": "deny callback",
+	"ZA": "allow callback object",
+	"XU": "audit callback",
+	"ML": "mandatory label",
+	"RA": "resource attribute",
+	"SP": "scoped policy",
+}
+
+// ACE flags, in rendering order
+var aceFlags = []namedValue{
+	{"OI", 0x01},
+	{"CI", 0x02},
+	{"NP", 0x04},
+	{"IO", 0x08},
+	{"ID", 0x10},
+	{"CR", 0x20},
+	{"SA", 0x40},
+	{"FA", 0x80},
+}
+
+// Exact combinations, tried first when rendering
+var rightsCombos = []namedValue{
+	{"FA", 0x1f01ff},
+	{"FR", 0x120089},
+	{"FW", 0x120116},
+	{"FX", 0x1200a0},
+	{"KA", 0xf003f},
+	{"KR", 0x20019},
+	{"KW", 0x20006},
+	{"KX", 0x20019},
+}
+
+// Single bits, in rendering order
+var rightsBits = []namedValue{
+	{"GA", 0x10000000},
+	{"GR", 0x80000000},
+	{"GW", 0x40000000},
+	{"GX", 0x20000000},
+	{"RC", 0x20000},
+	{"SD", 0x10000},
+	{"WD", 0x40000},
+	{"WO", 0x80000},
+	{"RP", 0x10},
+	{"WP", 0x20},
+	{"CC", 0x1},
+	{"DC", 0x2},
+	{"LC", 0x4},
+	{"SW", 0x8},
+	{"LO", 0x80},
+	{"DT", 0x40},
+	{"CR", 0x100},
+}
+
+// Mandatory label policy, ML ACEs only
+var labelBits = []namedValue{
+	{"NW", 0x1},
+	{"NR", 0x2},
+	{"NX", 0x4},
+}
+
+// TypeName returns a readable ACE type ("allow", "deny" ...)
+func TypeName(aceType string) string {
+	if name, found := aceTypeNames[aceType]; found {
+		return name
+	}
+	return "unknown"
+}
+
+// TypeCode returns the SDDL code of a binary ACE type, empty when unknown
+func TypeCode(aceType uint8) string {
+	for _, known := range aceTypes {
+		if known.value == uint32(aceType) {
+			return known.code
+		}
+	}
+	return ""
+}
+
+// FlagNames returns the SDDL codes of ACE flags (OI, CI, NP, IO, ID ...)
+func FlagNames(flags uint8) []string {
+	var names []string
+	for _, flag := range aceFlags {
+		if uint32(flags)&flag.value != 0 {
+			names = append(names, flag.code)
+		}
+	}
+	return names
+}
+
+func parseFlags(value string) (uint8, error) {
+	var flags uint8
+	for len(value) > 0 {
+		if len(value) < 2 {
+			return 0, fmt.Errorf("invalid ACE flags %q", value)
+		}
+		found := false
+		for _, flag := range aceFlags {
+			if value[:2] == flag.code {
+				flags |= uint8(flag.value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown ACE flag %q", value[:2])
+		}
+		value = value[2:]
+	}
+	return flags, nil
+}
+
+func renderFlags(flags uint8) string {
+	return strings.Join(FlagNames(flags), "")
+}
+
+// parseRights reads "0x1f01ff", a decimal mask, or concatenated codes ("FA", "CCLCSWRPWPDTLOCRSDRCWDWO")
+func parseRights(value string, aceType string) (uint32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if value[0] >= '0' && value[0] <= '9' {
+		mask, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid access mask %q", value)
+		}
+		return uint32(mask), nil
+	}
+
+	tables := [][]namedValue{rightsCombos, rightsBits}
+	if aceType == "ML" {
+		tables = [][]namedValue{labelBits}
+	}
+
+	var mask uint32
+	for len(value) > 0 {
+		if len(value) < 2 {
+			return 0, fmt.Errorf("invalid access rights %q", value)
+		}
+		found := false
+		for _, table := range tables {
+			for _, right := range table {
+				if value[:2] == right.code {
+					mask |= right.value
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown access right %q", value[:2])
+		}
+		value = value[2:]
+	}
+	return mask, nil
+}
+
+// renderRights uses an exact combination, then single bits, and hexadecimal when some bits have no code
+func renderRights(mask uint32, aceType string) string {
+	if aceType == "ML" {
+		return renderBits(mask, labelBits)
+	}
+	for _, combo := range rightsCombos {
+		if mask == combo.value {
+			return combo.code
+		}
+	}
+	return renderBits(mask, rightsBits)
+}
+
+func renderBits(mask uint32, bits []namedValue) string {
+	var codes strings.Builder
+	remaining := mask
+	for _, bit := range bits {
+		if mask&bit.value != 0 {
+			codes.WriteString(bit.code)
+			remaining &^= bit.value
+		}
+	}
+	if remaining != 0 || mask == 0 {
+		return fmt.Sprintf("0x%x", mask)
+	}
+	return codes.String()
+}
+
+// Same as icacls F, M, RX, R and W
+var fileRightsCombos = []namedValue{
+	{"full control", 0x1f01ff},
+	{"modify", 0x1301bf},
+	{"read & execute", 0x1200a9},
+	{"read", 0x120089},
+	{"write", 0x100116},
+}
+
+// Specific rights differ between files, directories and pipes, standard and generic ones don't
+var fileRightsSpecific = map[string]map[uint32]string{
+	"file": {0x1: "read data", 0x2: "write data", 0x4: "append data", 0x20: "execute"},
+	"dir":  {0x1: "list", 0x2: "add file", 0x4: "add subdirectory", 0x20: "traverse"},
+	"pipe": {0x1: "read data", 0x2: "write data", 0x4: "create instance", 0x20: "execute"},
+}
+
+var fileRightsCommon = map[uint32]string{
+	0x8:        "read EA",
+	0x10:       "write EA",
+	0x40:       "delete child",
+	0x80:       "read attributes",
+	0x100:      "write attributes",
+	0x10000:    "delete",
+	0x20000:    "read control",
+	0x40000:    "write DAC",
+	0x80000:    "write owner",
+	0x100000:   "synchronize",
+	0x1000000:  "access system security",
+	0x10000000: "generic all",
+	0x20000000: "generic execute",
+	0x40000000: "generic write",
+	0x80000000: "generic read",
+}
+
+// Mandatory label policy names
+var labelNames = map[uint32]string{0x1: "no write up", 0x2: "no read up", 0x4: "no execute up"}
+
+// FileRights names the rights of a file, directory or pipe ("file", "dir", "pipe") access mask
+// Well-known combinations are named as icacls does, otherwise each bit is named, unknown ones in hexadecimal
+func FileRights(mask uint32, kind string) []string {
+	for _, combo := range fileRightsCombos {
+		if mask == combo.value {
+			return []string{combo.code}
+		}
+	}
+
+	specific, found := fileRightsSpecific[kind]
+	if !found {
+		specific = fileRightsSpecific["file"]
+	}
+
+	var rights []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if mask&bit == 0 {
+			continue
+		}
+		if name, found := specific[bit]; found {
+			rights = append(rights, name)
+		} else if name, found := fileRightsCommon[bit]; found {
+			rights = append(rights, name)
+		} else {
+			rights = append(rights, fmt.Sprintf("%#x", bit))
+		}
+	}
+	return rights
+}
+
+// Rights names the rights of the ACE, as FileRights does, or its policy for a mandatory label
+func (ace ACE) Rights(kind string) []string {
+	if ace.Type != "ML" {
+		return FileRights(ace.Mask, kind)
+	}
+	var rights []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if ace.Mask&bit == 0 {
+			continue
+		}
+		if name, found := labelNames[bit]; found {
+			rights = append(rights, name)
+		} else {
+			rights = append(rights, fmt.Sprintf("%#x", bit))
+		}
+	}
+	return rights
+}
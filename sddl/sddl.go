@@ -0,0 +1,259 @@
+// Package sddl parses and renders Security Descriptor Definition Language strings,
+// without any Windows API, so descriptors captured on a target can be analysed anywhere.
+package sddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecurityDescriptor is the parsed form of an SDDL string
+// A nil Dacl with HasDacl set is a null DACL (D:NO_ACCESS_CONTROL), anyone has full access
+type SecurityDescriptor struct {
+	Owner     SID    `json:"owner,omitempty"`
+	Group     SID    `json:"group,omitempty"`
+	HasDacl   bool   `json:"has_dacl"`
+	DaclFlags string `json:"dacl_flags,omitempty"` // P, AI, AR, NO_ACCESS_CONTROL
+	Dacl      []ACE  `json:"dacl,omitempty"`
+	HasSacl   bool   `json:"has_sacl"`
+	SaclFlags string `json:"sacl_flags,omitempty"`
+	Sacl      []ACE  `json:"sacl,omitempty"`
+}
+
+// ACE is one access control entry
+type ACE struct {
+	Type              string `json:"type"` // SDDL code: A, D, AU, OA, ML ...
+	Flags             uint8  `json:"flags"`
+	Mask              uint32 `json:"mask"`
+	ObjectType        string `json:"object_type,omitempty"`         // GUID, object ACEs only
+	InheritObjectType string `json:"inherit_object_type,omitempty"` // GUID, object ACEs only
+	SID               SID    `json:"sid"`
+	Extra             string `json:"extra,omitempty"` // condition or resource attribute, kept as is
+}
+
+// Descriptor control flags of DACL and SACL, longest first
+var controlFlags = []string{"NO_ACCESS_CONTROL", "AI", "AR", "P"}
+
+// Parse reads an SDDL string such as "O:BAG:SYD:PAI(A;OICI;FA;;;SY)(A;;FR;;;WD)"
+func Parse(value string) (*SecurityDescriptor, error) {
+	sd := &SecurityDescriptor{}
+	p := &parser{value: strings.TrimSpace(value)}
+
+	seen := make(map[byte]bool)
+	for !p.done() {
+		component, err := p.component()
+		if err != nil {
+			return nil, err
+		}
+		if seen[component] {
+			return nil, fmt.Errorf("duplicate %c: component", component)
+		}
+		seen[component] = true
+
+		switch component {
+		case 'O', 'G':
+			value := p.untilComponent()
+			sid, ok := parseSID(value)
+			if !ok {
+				return nil, fmt.Errorf("invalid SID %q", value)
+			}
+			if component == 'O' {
+				sd.Owner = sid
+			} else {
+				sd.Group = sid
+			}
+		case 'D':
+			sd.HasDacl = true
+			sd.DaclFlags, sd.Dacl, err = p.acl()
+		case 'S':
+			sd.HasSacl = true
+			sd.SaclFlags, sd.Sacl, err = p.acl()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sd, nil
+}
+
+// String renders the descriptor back to SDDL, with SID aliases and rights codes when possible
+func (sd *SecurityDescriptor) String() string {
+	var out strings.Builder
+	if sd.Owner != "" {
+		out.WriteString("O:" + sd.Owner.sddlString())
+	}
+	if sd.Group != "" {
+		out.WriteString("G:" + sd.Group.sddlString())
+	}
+	if sd.HasDacl {
+		out.WriteString("D:" + sd.DaclFlags)
+		for _, ace := range sd.Dacl {
+			out.WriteString(ace.String())
+		}
+	}
+	if sd.HasSacl {
+		out.WriteString("S:" + sd.SaclFlags)
+		for _, ace := range sd.Sacl {
+			out.WriteString(ace.String())
+		}
+	}
+	return out.String()
+}
+
+// NullDacl tells if anyone has full access
+func (sd *SecurityDescriptor) NullDacl() bool {
+	return sd.HasDacl && strings.Contains(sd.DaclFlags, "NO_ACCESS_CONTROL")
+}
+
+// String renders the ACE as "(type;flags;rights;object;inherit object;sid)"
+func (ace ACE) String() string {
+	fields := []string{
+		ace.Type,
+		renderFlags(ace.Flags),
+		renderRights(ace.Mask, ace.Type),
+		ace.ObjectType,
+		ace.InheritObjectType,
+		ace.SID.sddlString(),
+	}
+	if ace.Extra != "" {
+		fields = append(fields, ace.Extra)
+	}
+	return "(" + strings.Join(fields, ";") + ")"
+}
+
+// TypeName returns a readable type ("allow", "deny" ...)
+func (ace ACE) TypeName() string {
+	return TypeName(ace.Type)
+}
+
+func parseACE(value string) (ACE, error) {
+	var ace ACE
+	// The 7th field (condition) may hold ";" itself
+	fields := strings.SplitN(value, ";", 7)
+	if len(fields) < 6 {
+		return ace, fmt.Errorf("invalid ACE %q, 6 fields expected", value)
+	}
+
+	ace.Type = fields[0]
+	if _, found := aceTypeNames[ace.Type]; !found {
+		return ace, fmt.Errorf("unknown ACE type %q", ace.Type)
+	}
+
+	var err error
+	if ace.Flags, err = parseFlags(fields[1]); err != nil {
+		return ace, err
+	}
+	if ace.Mask, err = parseRights(fields[2], ace.Type); err != nil {
+		return ace, err
+	}
+	ace.ObjectType = fields[3]
+	ace.InheritObjectType = fields[4]
+
+	sid, ok := parseSID(fields[5])
+	if !ok {
+		return ace, fmt.Errorf("invalid SID %q", fields[5])
+	}
+	ace.SID = sid
+
+	if len(fields) == 7 {
+		ace.Extra = fields[6]
+	}
+	return ace, nil
+}
+
+type parser struct {
+	value    string
+	position int
+}
+
+func (p *parser) done() bool {
+	return p.position >= len(p.value)
+}
+
+// atComponent tells if "O:", "G:", "D:" or "S:" starts at the current position
+func (p *parser) atComponent() bool {
+	if p.position+1 >= len(p.value) || p.value[p.position+1] != ':' {
+		return false
+	}
+	return strings.IndexByte("OGDS", p.value[p.position]) >= 0
+}
+
+func (p *parser) component() (byte, error) {
+	if !p.atComponent() {
+		return 0, fmt.Errorf("expected O:, G:, D: or S: at offset %d", p.position)
+	}
+	component := p.value[p.position]
+	p.position += 2
+	return component, nil
+}
+
+// untilComponent returns everything up to the next component
+func (p *parser) untilComponent() string {
+	start := p.position
+	for !p.done() && !p.atComponent() {
+		p.position++
+	}
+	return p.value[start:p.position]
+}
+
+// acl reads control flags then ACEs, up to the next component
+func (p *parser) acl() (string, []ACE, error) {
+	var flags strings.Builder
+	for !p.done() && !p.atComponent() && p.value[p.position] != '(' {
+		found := false
+		for _, flag := range controlFlags {
+			if strings.HasPrefix(p.value[p.position:], flag) {
+				flags.WriteString(flag)
+				p.position += len(flag)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", nil, fmt.Errorf("unknown ACL flag at offset %d", p.position)
+		}
+	}
+
+	var aces []ACE
+	for !p.done() && !p.atComponent() {
+		if p.value[p.position] != '(' {
+			return "", nil, fmt.Errorf("expected ( at offset %d", p.position)
+		}
+		end, err := p.closingParenthesis()
+		if err != nil {
+			return "", nil, err
+		}
+		ace, err := parseACE(p.value[p.position+1 : end])
+		if err != nil {
+			return "", nil, err
+		}
+		aces = append(aces, ace)
+		p.position = end + 1
+	}
+	if strings.Contains(flags.String(), "NO_ACCESS_CONTROL") && len(aces) > 0 {
+		return "", nil, fmt.Errorf("NO_ACCESS_CONTROL with ACEs")
+	}
+	return flags.String(), aces, nil
+}
+
+// closingParenthesis finds the end of the ACE starting at the current position
+// Conditions nest parentheses and may hold them in quoted strings
+func (p *parser) closingParenthesis() (int, error) {
+	depth := 0
+	quoted := false
+	for index := p.position; index < len(p.value); index++ {
+		switch char := p.value[index]; {
+		case char == '"':
+			quoted = !quoted
+		case quoted:
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth == 0 {
+				return index, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated ACE at offset %d", p.position)
+}
@@ -0,0 +1,174 @@
+package sddl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"file", "O:BAG:SYD:PAI(A;OICI;FA;;;SY)(A;OICIIO;GA;;;CO)(A;;0x1200a9;;;BU)", ""},
+		{"full sids become aliases", "O:S-1-5-32-544G:S-1-5-18D:(A;;FA;;;S-1-1-0)", "O:BAG:SYD:(A;;FA;;;WD)"},
+		{"domain alias", "O:DAD:(A;;FR;;;DU)", ""},
+		{"unknown sid", "D:(D;;FW;;;S-1-5-21-1111-2222-3333-1105)", ""},
+		{"null dacl", "D:NO_ACCESS_CONTROL", ""},
+		{"empty dacl", "D:P", ""},
+		{"rights codes are reordered", "D:(A;;CCDCLCSWRPWPDTLOCRSDRCWDWO;;;AU)", "D:(A;;RCSDWDWORPWPCCDCLCSWLODTCR;;;AU)"},
+		{"decimal mask", "D:(A;;2032127;;;SY)", "D:(A;;FA;;;SY)"},
+		{"object ace", "D:(OA;CI;CR;ab721a53-1e2f-11d0-9819-00aa0040529b;bf967aba-0de6-11d0-a285-00aa003049e2;PS)", ""},
+		{"conditional ace", `D:(XA;;FX;;;WD;(@User.Title == "PM" && (@User.Dept == ")(")))`, ""},
+		{"sacl", "S:AI(AU;SAFA;FA;;;WD)(ML;;NWNR;;;HI)", ""},
+		{"dacl and sacl", "D:AI(A;ID;FA;;;BA)S:(ML;;NW;;;LW)", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sd, err := Parse(test.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", test.input, err)
+			}
+			want := test.want
+			if want == "" {
+				want = test.input
+			}
+			if got := sd.String(); got != want {
+				t.Errorf("String() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	sd, err := Parse("O:BAG:SYD:PAI(A;OICIID;FA;;;SY)(D;;FW;;;S-1-5-21-1-2-3-1105)S:(ML;;NW;;;ME)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &SecurityDescriptor{
+		Owner:     "S-1-5-32-544",
+		Group:     "S-1-5-18",
+		HasDacl:   true,
+		DaclFlags: "PAI",
+		Dacl: []ACE{
+			{Type: "A", Flags: 0x13, Mask: 0x1f01ff, SID: "S-1-5-18"},
+			{Type: "D", Mask: 0x120116, SID: "S-1-5-21-1-2-3-1105"},
+		},
+		HasSacl: true,
+		Sacl: []ACE{
+			{Type: "ML", Mask: 0x1, SID: "S-1-16-8192"},
+		},
+	}
+	if !reflect.DeepEqual(sd, want) {
+		t.Errorf("Parse() = %+v, want %+v", sd, want)
+	}
+	if sd.NullDacl() {
+		t.Errorf("NullDacl() = true")
+	}
+	if got := sd.Dacl[1].TypeName(); got != "deny" {
+		t.Errorf("TypeName() = %q, want deny", got)
+	}
+}
+
+func TestNullDacl(t *testing.T) {
+	sd, err := Parse("O:SYD:NO_ACCESS_CONTROL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sd.NullDacl() || len(sd.Dacl) != 0 {
+		t.Errorf("NullDacl() = %v with %d ACEs", sd.NullDacl(), len(sd.Dacl))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"X:SY",
+		"O:XX",
+		"O:SYO:BA",
+		"O:S-1-",
+		"D:(A;;FA;;SY)",
+		"D:(Z;;FA;;;SY)",
+		"D:(A;XX;FA;;;SY)",
+		"D:(A;;QQ;;;SY)",
+		"D:(A;;0xZZ;;;SY)",
+		"D:(A;;FA;;;SY",
+		"D:QQ(A;;FA;;;SY)",
+		"D:NO_ACCESS_CONTROL(A;;FA;;;SY)",
+		"D:(A;;FA;;;SY)junk",
+	}
+	for _, input := range tests {
+		if sd, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %q, want an error", input, sd.String())
+		}
+	}
+}
+
+func TestSIDName(t *testing.T) {
+	tests := []struct {
+		sid  SID
+		want string
+	}{
+		{"S-1-1-0", "Everyone"},
+		{"S-1-5-11", `NT AUTHORITY\Authenticated Users`},
+		{"S-1-5-18", `NT AUTHORITY\SYSTEM`},
+		{"S-1-5-32-544", `BUILTIN\Administrators`},
+		{"DA", "Domain Admins"},
+		{"S-1-5-21-1111-2222-3333-512", "Domain Admins"},
+		{"S-1-5-21-1111-2222-3333-502", "krbtgt"},
+		{"S-1-5-21-1111-2222-3333-1105", ""},
+		{"S-1-5-80-956008885-3418522649-1831038044-1853292631-2271478464", `NT SERVICE\TrustedInstaller`},
+		{"S-1-5-99", ""},
+	}
+	for _, test := range tests {
+		if got := test.sid.Name(); got != test.want {
+			t.Errorf("SID(%q).Name() = %q, want %q", test.sid, got, test.want)
+		}
+	}
+}
+
+func TestFileRights(t *testing.T) {
+	tests := []struct {
+		mask uint32
+		kind string
+		want []string
+	}{
+		{0x1f01ff, "file", []string{"full control"}},
+		{0x1200a9, "dir", []string{"read & execute"}},
+		{0x6, "dir", []string{"add file", "add subdirectory"}},
+		{0x6, "file", []string{"write data", "append data"}},
+		{0x4, "pipe", []string{"create instance"}},
+		{0x40000000, "file", []string{"generic write"}},
+		{0x2010000, "file", []string{"delete", "0x2000000"}},
+		{0, "file", nil},
+	}
+	for _, test := range tests {
+		if got := FileRights(test.mask, test.kind); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FileRights(%#x, %s) = %q, want %q", test.mask, test.kind, got, test.want)
+		}
+	}
+}
+
+func TestLabelRights(t *testing.T) {
+	ace := ACE{Type: "ML", Mask: 0x3, SID: "S-1-16-12288"}
+	if got := ace.Rights("file"); !reflect.DeepEqual(got, []string{"no write up", "no read up"}) {
+		t.Errorf("Rights() = %q", got)
+	}
+	ace.Type = "A"
+	if got := ace.Rights("file"); !reflect.DeepEqual(got, []string{"read data", "write data"}) {
+		t.Errorf("Rights() = %q", got)
+	}
+}
+
+func TestCodes(t *testing.T) {
+	if got := TypeCode(1); got != "D" {
+		t.Errorf("TypeCode(1) = %q, want D", got)
+	}
+	if got := TypeCode(0x42); got != "" {
+		t.Errorf("TypeCode(0x42) = %q, want empty", got)
+	}
+	if got := FlagNames(0x1b); !reflect.DeepEqual(got, []string{"OI", "CI", "IO", "ID"}) {
+		t.Errorf("FlagNames(0x1b) = %q", got)
+	}
+}
@@ -0,0 +1,165 @@
+package sddl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SID in string form "S-1-5-18", or a domain relative alias ("DA") when the domain is unknown
+type SID string
+
+type wellKnownSid struct {
+	alias string
+	sid   string // empty for domain relative aliases
+	rid   uint32 // domain relative aliases only
+	name  string
+}
+
+// SDDL SID strings, names as returned by LookupAccountSid on an English system
+var wellKnownSids = []wellKnownSid{
+	{"AA", "S-1-5-32-579", 0, `BUILTIN\Access Control Assistance Operators`},
+	{"AC", "S-1-15-2-1", 0, `APPLICATION PACKAGE AUTHORITY\ALL APPLICATION PACKAGES`},
+	{"AN", "S-1-5-7", 0, `NT AUTHORITY\ANONYMOUS LOGON`},
+	{"AO", "S-1-5-32-548", 0, `BUILTIN\Account Operators`},
+	{"AP", "", 525, "Protected Users"},
+	{"AS", "S-1-18-1", 0, "Authentication authority asserted identity"},
+	{"AU", "S-1-5-11", 0, `NT AUTHORITY\Authenticated Users`},
+	{"BA", "S-1-5-32-544", 0, `BUILTIN\Administrators`},
+	{"BG", "S-1-5-32-546", 0, `BUILTIN\Guests`},
+	{"BO", "S-1-5-32-551", 0, `BUILTIN\Backup Operators`},
+	{"BU", "S-1-5-32-545", 0, `BUILTIN\Users`},
+	{"CA", "", 517, "Cert Publishers"},
+	{"CD", "S-1-5-32-574", 0, `BUILTIN\Certificate Service DCOM Access`},
+	{"CG", "S-1-3-1", 0, "CREATOR GROUP"},
+	{"CN", "", 522, "Cloneable Domain Controllers"},
+	{"CO", "S-1-3-0", 0, "CREATOR OWNER"},
+	{"CY", "S-1-5-32-569", 0, `BUILTIN\Cryptographic Operators`},
+	{"DA", "", 512, "Domain Admins"},
+	{"DC", "", 515, "Domain Computers"},
+	{"DD", "", 516, "Domain Controllers"},
+	{"DG", "", 514, "Domain Guests"},
+	{"DU", "", 513, "Domain Users"},
+	{"EA", "", 519, "Enterprise Admins"},
+	{"ED", "S-1-5-9", 0, `NT AUTHORITY\ENTERPRISE DOMAIN CONTROLLERS`},
+	{"ER", "S-1-5-32-573", 0, `BUILTIN\Event Log Readers`},
+	{"ES", "S-1-5-32-576", 0, `BUILTIN\RDS Endpoint Servers`},
+	{"HA", "S-1-5-32-578", 0, `BUILTIN\Hyper-V Administrators`},
+	{"HI", "S-1-16-12288", 0, `Mandatory Label\High Mandatory Level`},
+	{"IS", "S-1-5-32-568", 0, `BUILTIN\IIS_IUSRS`},
+	{"IU", "S-1-5-4", 0, `NT AUTHORITY\INTERACTIVE`},
+	{"LA", "", 500, "Administrator"},
+	{"LG", "", 501, "Guest"},
+	{"LS", "S-1-5-19", 0, `NT AUTHORITY\LOCAL SERVICE`},
+	{"LU", "S-1-5-32-559", 0, `BUILTIN\Performance Log Users`},
+	{"LW", "S-1-16-4096", 0, `Mandatory Label\Low Mandatory Level`},
+	{"ME", "S-1-16-8192", 0, `Mandatory Label\Medium Mandatory Level`},
+	{"MP", "S-1-16-8448", 0, `Mandatory Label\Medium Plus Mandatory Level`},
+	{"MU", "S-1-5-32-558", 0, `BUILTIN\Performance Monitor Users`},
+	{"NO", "S-1-5-32-556", 0, `BUILTIN\Network Configuration Operators`},
+	{"NS", "S-1-5-20", 0, `NT AUTHORITY\NETWORK SERVICE`},
+	{"NU", "S-1-5-2", 0, `NT AUTHORITY\NETWORK`},
+	{"OW", "S-1-3-4", 0, "OWNER RIGHTS"},
+	{"PA", "", 520, "Group Policy Creator Owners"},
+	{"PO", "S-1-5-32-550", 0, `BUILTIN\Print Operators`},
+	{"PS", "S-1-5-10", 0, `NT AUTHORITY\SELF`},
+	{"PU", "S-1-5-32-547", 0, `BUILTIN\Power Users`},
+	{"RA", "S-1-5-32-575", 0, `BUILTIN\RDS Remote Access Servers`},
+	{"RC", "S-1-5-12", 0, `NT AUTHORITY\RESTRICTED`},
+	{"RD", "S-1-5-32-555", 0, `BUILTIN\Remote Desktop Users`},
+	{"RE", "S-1-5-32-552", 0, `BUILTIN\Replicator`},
+	{"RM", "S-1-5-32-580", 0, `BUILTIN\Remote Management Users`},
+	{"RO", "", 498, "Enterprise Read-only Domain Controllers"},
+	{"RS", "", 553, "RAS and IAS Servers"},
+	{"RU", "S-1-5-32-554", 0, `BUILTIN\Pre-Windows 2000 Compatible Access`},
+	{"SA", "", 518, "Schema Admins"},
+	{"SI", "S-1-16-16384", 0, `Mandatory Label\System Mandatory Level`},
+	{"SO", "S-1-5-32-549", 0, `BUILTIN\Server Operators`},
+	{"SS", "S-1-18-2", 0, "Service asserted identity"},
+	{"SU", "S-1-5-6", 0, `NT AUTHORITY\SERVICE`},
+	{"SY", "S-1-5-18", 0, `NT AUTHORITY\SYSTEM`},
+	{"UD", "S-1-5-84-0-0-0-0-0", 0, `NT AUTHORITY\USER MODE DRIVERS`},
+	{"WD", "S-1-1-0", 0, "Everyone"},
+	{"WR", "S-1-5-33", 0, `NT AUTHORITY\WRITE RESTRICTED`},
+}
+
+// Well-known SIDs without SDDL alias
+var otherSids = map[string]string{
+	"S-1-5-80-956008885-3418522649-1831038044-1853292631-2271478464": `NT SERVICE\TrustedInstaller`,
+	"S-1-15-2-2":   `APPLICATION PACKAGE AUTHORITY\ALL RESTRICTED APPLICATION PACKAGES`,
+	"S-1-5-1":      `NT AUTHORITY\DIALUP`,
+	"S-1-5-3":      `NT AUTHORITY\BATCH`,
+	"S-1-5-13":     `NT AUTHORITY\TERMINAL SERVER USER`,
+	"S-1-5-14":     `NT AUTHORITY\REMOTE INTERACTIVE LOGON`,
+	"S-1-5-15":     `NT AUTHORITY\This Organization`,
+	"S-1-5-32-562": `BUILTIN\Distributed COM Users`,
+	"S-1-5-113":    `NT AUTHORITY\Local account`,
+	"S-1-5-114":    `NT AUTHORITY\Local account and member of Administrators group`,
+	"S-1-16-0":     `Mandatory Label\Untrusted Mandatory Level`,
+}
+
+// Domain accounts without SDDL alias, by RID
+var domainRids = map[uint32]string{
+	502: "krbtgt",
+	521: "Read-only Domain Controllers",
+	526: "Key Admins",
+	527: "Enterprise Key Admins",
+}
+
+var sidPattern = regexp.MustCompile(`^S-1-\d+(-\d+)*$`)
+
+// parseSID reads a SID string or an SDDL alias, fixed aliases are expanded
+func parseSID(value string) (SID, bool) {
+	if sidPattern.MatchString(value) {
+		return SID(value), true
+	}
+	for _, known := range wellKnownSids {
+		if known.alias == value {
+			if known.sid != "" {
+				return SID(known.sid), true
+			}
+			return SID(value), true
+		}
+	}
+	return "", false
+}
+
+// sddlString returns the alias when there is one, as ConvertSecurityDescriptorToStringSecurityDescriptor does
+func (sid SID) sddlString() string {
+	for _, known := range wellKnownSids {
+		if known.sid != "" && string(sid) == known.sid {
+			return known.alias
+		}
+	}
+	return string(sid)
+}
+
+// Name returns the account name of well-known and domain SIDs, without any lookup
+// Unknown SIDs return an empty string
+func (sid SID) Name() string {
+	value := string(sid)
+	for _, known := range wellKnownSids {
+		if value == known.alias || (known.sid != "" && value == known.sid) {
+			return known.name
+		}
+	}
+	if name, found := otherSids[value]; found {
+		return name
+	}
+
+	// S-1-5-21-<domain>-<rid>
+	if strings.HasPrefix(value, "S-1-5-21-") {
+		parts := strings.Split(value, "-")
+		rid, err := strconv.ParseUint(parts[len(parts)-1], 10, 32)
+		if err != nil || len(parts) != 8 {
+			return ""
+		}
+		for _, known := range wellKnownSids {
+			if known.sid == "" && known.rid == uint32(rid) {
+				return known.name
+			}
+		}
+		return domainRids[uint32(rid)]
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+// Package watch reports file system changes below a set of roots.
+//
+// Linux runs one recursive inotify instance per root, Windows reads every
+// root with overlapped ReadDirectoryChangesW on a single I/O completion port.
+// Other systems get ErrUnsupported from Add, Supported tells which one is built.
+package watch
+
+// Action is what happened to a path
+type Action uint32
+
+// The first ones are the Windows FILE_ACTION_* values, the others are gofspy's own
+const (
+	Added          Action = 0x00000001
+	Removed        Action = 0x00000002
+	Modified       Action = 0x00000003
+	RenamedOldName Action = 0x00000004
+	RenamedNewName Action = 0x00000005
+	Existing       Action = 0x10101010 // listed at start
+	Opened         Action = 0x10101011
+	ClosedWrite    Action = 0x10101012
+	Renamed        Action = 0x10101013 // old and new names paired
+	MovedOut       Action = 0x10101014 // old name without new name
+	MovedIn        Action = 0x10101015 // new name without old name
+	Overflow       Action = 0x10101016 // watcher lost events
+)
+
+func (a Action) String() string {
+	switch a {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	case RenamedOldName:
+		return "renamed_old"
+	case RenamedNewName:
+		return "renamed_new"
+	case Existing:
+		return "existing"
+	case Opened:
+		return "opened"
+	case ClosedWrite:
+		return "closed_write"
+	case Renamed:
+		return "renamed"
+	case MovedOut:
+		return "moved_out"
+	case MovedIn:
+		return "moved_in"
+	case Overflow:
+		return "overflow"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,58 @@
+package watch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Fixed part of FILE_NOTIFY_INFORMATION: NextEntryOffset, Action, FileNameLength
+const notifyHeaderSize = 12
+
+// notifyRecord is one FILE_NOTIFY_INFORMATION entry, name is relative to the watched directory
+type notifyRecord struct {
+	action Action
+	name   string
+}
+
+// decodeNotifyInformation walks the FILE_NOTIFY_INFORMATION records of buffer
+// Every offset and length is checked against buffer, a malformed record ends the walk
+// with the records decoded so far and an error
+func decodeNotifyInformation(buffer []byte) ([]notifyRecord, error) {
+	var records []notifyRecord
+	offset := 0
+	for offset < len(buffer) {
+		if len(buffer)-offset < notifyHeaderSize {
+			return records, fmt.Errorf("truncated record at offset %d", offset)
+		}
+		// Kept as uint32 until checked, int is 32 bits on 386
+		next := binary.LittleEndian.Uint32(buffer[offset:])
+		action := Action(binary.LittleEndian.Uint32(buffer[offset+4:]))
+		nameLength := binary.LittleEndian.Uint32(buffer[offset+8:])
+
+		nameStart := offset + notifyHeaderSize
+		if nameLength%2 != 0 {
+			return records, fmt.Errorf("odd name length %d at offset %d", nameLength, offset)
+		}
+		if uint64(nameLength) > uint64(len(buffer)-nameStart) {
+			return records, fmt.Errorf("name length %d past the end at offset %d", nameLength, offset)
+		}
+
+		name := make([]uint16, nameLength/2)
+		for i := range name {
+			name[i] = binary.LittleEndian.Uint16(buffer[nameStart+2*i:])
+		}
+		// Unpaired surrogates become U+FFFD
+		records = append(records, notifyRecord{action: action, name: string(utf16.Decode(name))})
+
+		if next == 0 {
+			return records, nil
+		}
+		// The next record can't overlap this one, and must be in the buffer
+		if uint64(next) < notifyHeaderSize+uint64(nameLength) || uint64(next) > uint64(len(buffer)-offset) {
+			return records, fmt.Errorf("invalid next entry offset %d at offset %d", next, offset)
+		}
+		offset += int(next)
+	}
+	return records, nil
+}
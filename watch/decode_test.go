@@ -0,0 +1,159 @@
+package watch
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// Golden buffers, as ReadDirectoryChangesW fills them (little endian, records aligned on 4 bytes)
+var (
+	// added a.txt
+	goldenAdded = []byte{
+		0x00, 0x00, 0x00, 0x00, // NextEntryOffset, last record
+		0x01, 0x00, 0x00, 0x00, // Action, FILE_ACTION_ADDED
+		0x0a, 0x00, 0x00, 0x00, // FileNameLength, 10 bytes
+		'a', 0, '.', 0, 't', 0, 'x', 0, 't', 0,
+	}
+
+	// old renamed to new, 12+6 bytes padded to 20
+	goldenRename = []byte{
+		0x14, 0x00, 0x00, 0x00,
+		0x04, 0x00, 0x00, 0x00, // FILE_ACTION_RENAMED_OLD_NAME
+		0x06, 0x00, 0x00, 0x00,
+		'o', 0, 'l', 0, 'd', 0, 0, 0,
+		0x00, 0x00, 0x00, 0x00,
+		0x05, 0x00, 0x00, 0x00, // FILE_ACTION_RENAMED_NEW_NAME
+		0x06, 0x00, 0x00, 0x00,
+		'n', 0, 'e', 0, 'w', 0,
+	}
+
+	// modified dir\é😀, a subdirectory, a BMP character and a surrogate pair
+	goldenUnicode = []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x00, // FILE_ACTION_MODIFIED
+		0x0e, 0x00, 0x00, 0x00,
+		'd', 0, 'i', 0, 'r', 0, '\\', 0, 0xe9, 0x00, 0x3d, 0xd8, 0x00, 0xde,
+	}
+)
+
+func TestDecodeNotifyInformation(t *testing.T) {
+	tests := []struct {
+		name   string
+		buffer []byte
+		want   []notifyRecord
+	}{
+		{"empty", nil, nil},
+		{"added", goldenAdded, []notifyRecord{{Added, "a.txt"}}},
+		{"rename", goldenRename, []notifyRecord{{RenamedOldName, "old"}, {RenamedNewName, "new"}}},
+		{"unicode", goldenUnicode, []notifyRecord{{Modified, "dir\\é😀"}}},
+		{"unpaired surrogate", []byte{0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 0x3d, 0xd8}, []notifyRecord{{Added, "�"}}},
+		{"empty name", []byte{0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}, []notifyRecord{{Removed, ""}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeNotifyInformation(test.buffer)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeNotifyInformationMalformed(t *testing.T) {
+	// Changes header fields of a copy of goldenRename
+	rename := func(change func(buffer []byte)) []byte {
+		buffer := append([]byte(nil), goldenRename...)
+		change(buffer)
+		return buffer
+	}
+	tests := []struct {
+		name   string
+		buffer []byte
+		want   []notifyRecord // decoded before the bad record
+	}{
+		{"truncated header", goldenAdded[:8], nil},
+		{"truncated name", goldenAdded[:len(goldenAdded)-2], nil},
+		{"odd name length", rename(func(b []byte) { b[8] = 5 }), nil},
+		{"huge name length", rename(func(b []byte) { binary.LittleEndian.PutUint32(b[8:], 0xfffffffe) }), nil},
+		{"overlapping next", rename(func(b []byte) { b[0] = 8 }), []notifyRecord{{RenamedOldName, "old"}}},
+		{"next past the end", rename(func(b []byte) { binary.LittleEndian.PutUint32(b[0:], 0xfffffff0) }), []notifyRecord{{RenamedOldName, "old"}}},
+		{"bad second record", rename(func(b []byte) { b[28] = 0x40 }), []notifyRecord{{RenamedOldName, "old"}}},
+		{"garbage after the records", append(append([]byte(nil), goldenRename[:20]...), 1, 2, 3), []notifyRecord{{RenamedOldName, "old"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeNotifyInformation(test.buffer)
+			if err == nil {
+				t.Fatalf("no error, got %+v", got)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// encodeRecords builds a buffer the way Windows does, for the fuzz seeds
+func encodeRecords(records []notifyRecord) []byte {
+	var buffer []byte
+	for i, record := range records {
+		name := utf16.Encode([]rune(record.name))
+		size := notifyHeaderSize + 2*len(name)
+		padded := (size + 3) &^ 3
+		next := padded
+		if i == len(records)-1 {
+			next = 0
+			padded = size
+		}
+		entry := make([]byte, padded)
+		binary.LittleEndian.PutUint32(entry[0:], uint32(next))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(record.action))
+		binary.LittleEndian.PutUint32(entry[8:], uint32(2*len(name)))
+		for j, unit := range name {
+			binary.LittleEndian.PutUint16(entry[notifyHeaderSize+2*j:], unit)
+		}
+		buffer = append(buffer, entry...)
+	}
+	return buffer
+}
+
+func TestEncodeRecordsGolden(t *testing.T) {
+	if got := encodeRecords([]notifyRecord{{RenamedOldName, "old"}, {RenamedNewName, "new"}}); !reflect.DeepEqual(got, goldenRename) {
+		t.Errorf("got % x, want % x", got, goldenRename)
+	}
+}
+
+func FuzzDecodeNotifyInformation(f *testing.F) {
+	f.Add(goldenAdded)
+	f.Add(goldenRename)
+	f.Add(goldenUnicode)
+	f.Add(encodeRecords([]notifyRecord{{Added, `a\b\c`}, {Modified, "ü"}, {Removed, ""}}))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0xfe, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, buffer []byte) {
+		records, err := decodeNotifyInformation(buffer)
+
+		// Each record takes at least a header, names come from the buffer
+		if len(records)*notifyHeaderSize > len(buffer) {
+			t.Fatalf("%d records from %d bytes", len(records), len(buffer))
+		}
+		for _, record := range records {
+			if len(utf16.Encode([]rune(record.name))) > len(buffer)/2 {
+				t.Fatalf("name %q longer than the buffer", record.name)
+			}
+		}
+
+		// A clean decode survives a round trip
+		if err == nil && len(records) > 0 {
+			again, err := decodeNotifyInformation(encodeRecords(records))
+			if err != nil || !reflect.DeepEqual(again, records) {
+				t.Fatalf("round trip: got %+v (%v), want %+v", again, err, records)
+			}
+		}
+	})
+}
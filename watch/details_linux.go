@@ -0,0 +1,34 @@
+package watch
+
+import (
+	"os/user"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Uid returns the user name of uid, or the uid when unknown
+func (names *Names) Uid(uid uint32) string {
+	raw := strconv.FormatUint(uint64(uid), 10)
+	return names.Resolve("uid:"+raw, raw, func() (string, error) {
+		account, err := user.LookupId(raw)
+		if err != nil {
+			return "", err
+		}
+		return account.Username, nil
+	})
+}
+
+// pathOwner returns the user name, or the uid when unknown
+func pathOwner(path string, names *Names) string {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return ""
+	}
+	return names.Uid(stat.Uid)
+}
+
+// pathAccess uses access(2) with the real ids
+func pathAccess(path string) string {
+	return accessString(unix.Access(path, unix.R_OK) == nil, unix.Access(path, unix.W_OK) == nil)
+}
@@ -0,0 +1,64 @@
+package watch
+
+import (
+	"github.com/charlesgargasson/gofspy/sddl"
+	"golang.org/x/sys/windows"
+)
+
+// Sid returns DOMAIN\user for a SID string, through the cache
+// SDDL aliases and well-known SIDs fall back to their English name, then the SID itself
+func (names *Names) Sid(sid string) string {
+	return names.Resolve(sid, sid, func() (string, error) {
+		winSid, err := windows.StringToSid(sid)
+		if err == nil {
+			account, domain, _, lookupErr := winSid.LookupAccount("")
+			if lookupErr == nil && domain != "" {
+				return domain + `\` + account, nil
+			}
+			if lookupErr == nil {
+				return account, nil
+			}
+			err = lookupErr
+		}
+		if name := sddl.SID(sid).Name(); name != "" {
+			return name, nil
+		}
+		return "", err
+	})
+}
+
+// pathOwner returns DOMAIN\user, or the SID when it can't be resolved
+// It runs on the delivery goroutine of a watch, never on the completion port loop
+func pathOwner(path string, names *Names) string {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return ""
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return ""
+	}
+	return names.Sid(owner.String())
+}
+
+// pathAccess opens path for reading, then for writing
+func pathAccess(path string) string {
+	return accessString(canOpen(path, windows.GENERIC_READ), canOpen(path, windows.GENERIC_WRITE))
+}
+
+func canOpen(path string, access uint32) bool {
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		access,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	if err != nil {
+		return false
+	}
+	windows.CloseHandle(handle)
+	return true
+}
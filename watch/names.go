@@ -0,0 +1,75 @@
+package watch
+
+import (
+	"sync"
+	"time"
+)
+
+// Unresolvable ids are looked up again after this
+const nameRetry = 10 * time.Minute
+
+// Names caches account names of SIDs (Windows) and uids / gids (Linux)
+// Lookups may block on a domain controller or a directory, or fail
+// A Names can be shared with Options.Names, so a program and its watchers look each id up once
+type Names struct {
+	mu      sync.Mutex
+	entries map[string]*nameEntry
+	Timeout time.Duration // 0 waits for the lookup, set it before the first Resolve
+}
+
+type nameEntry struct {
+	name    string // empty when unresolvable
+	ready   chan struct{}
+	expires time.Time // unresolvable entries only
+}
+
+func NewNames(timeout time.Duration) *Names {
+	return &Names{entries: make(map[string]*nameEntry), Timeout: timeout}
+}
+
+// Resolve returns the cached name of key, or runs lookup once for all concurrent callers
+// After the timeout, raw is returned and the lookup goes on, its result serves next calls
+func (names *Names) Resolve(key string, raw string, lookup func() (string, error)) string {
+	names.mu.Lock()
+	entry, found := names.entries[key]
+	if !found || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		entry = &nameEntry{ready: make(chan struct{})}
+		names.entries[key] = entry
+		go func() {
+			name, err := lookup()
+			names.mu.Lock()
+			if err != nil || name == "" {
+				entry.expires = time.Now().Add(nameRetry)
+			} else {
+				entry.name = name
+			}
+			names.mu.Unlock()
+			close(entry.ready)
+		}()
+	}
+	names.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+	default:
+		if names.Timeout > 0 {
+			timer := time.NewTimer(names.Timeout)
+			defer timer.Stop()
+			select {
+			case <-entry.ready:
+			case <-timer.C:
+				return raw
+			}
+		} else {
+			<-entry.ready
+		}
+	}
+
+	names.mu.Lock()
+	name := entry.name
+	names.mu.Unlock()
+	if name == "" {
+		return raw
+	}
+	return name
+}
@@ -0,0 +1,54 @@
+package watch
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNamesResolve(t *testing.T) {
+	names := NewNames(20 * time.Millisecond)
+
+	// Slow lookups give the raw id, the name serves the next calls
+	release := make(chan struct{})
+	var lookups atomic.Int32
+	lookup := func() (string, error) {
+		lookups.Add(1)
+		<-release
+		return "alice", nil
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := names.Resolve("uid:1000", "1000", lookup); got != "1000" {
+				t.Errorf("timed out lookup = %q, want 1000", got)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for names.Resolve("uid:1000", "1000", lookup) != "alice" {
+		if time.Now().After(deadline) {
+			t.Fatal("name never cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d lookups, want 1", n)
+	}
+
+	// Failures are kept until nameRetry
+	failed := func() (string, error) {
+		lookups.Add(1)
+		return "", errors.New("unknown")
+	}
+	names.Resolve("uid:1001", "1001", failed)
+	if got := names.Resolve("uid:1001", "1001", failed); got != "1001" || lookups.Load() != 2 {
+		t.Errorf("failed lookup = %q after %d lookups", got, lookups.Load())
+	}
+}
@@ -0,0 +1,59 @@
+package watch
+
+// Raw notification from a source, before rename pairing
+type fileNotification struct {
+	action  Action
+	path    string
+	oldPath string
+	cookie  uint32 // inotify move cookie, 0 on Windows
+	rescan  bool   // recovered by a rescan after an overflow
+}
+
+// pairRenames merges old and new name notifications into single rename notifications
+// Windows sends RENAMED_NEW_NAME right after RENAMED_OLD_NAME, inotify links both with a cookie
+// Unmatched halves are moves out of or into the watched tree
+func pairRenames(notifications []fileNotification) []fileNotification {
+	var paired []fileNotification
+	used := make([]bool, len(notifications))
+
+	for i, notification := range notifications {
+		if used[i] {
+			continue
+		}
+
+		switch notification.action {
+		case RenamedOldName:
+			match := -1
+			for j := i + 1; j < len(notifications); j++ {
+				if !used[j] && notifications[j].action == RenamedNewName && notifications[j].cookie == notification.cookie {
+					match = j
+					break
+				}
+				// Without cookie, only the next record can be the new name
+				if notification.cookie == 0 {
+					break
+				}
+			}
+
+			if match < 0 {
+				notification.action = MovedOut
+				paired = append(paired, notification)
+				continue
+			}
+			used[match] = true
+			paired = append(paired, fileNotification{
+				action:  Renamed,
+				path:    notifications[match].path,
+				oldPath: notification.path,
+			})
+
+		case RenamedNewName:
+			notification.action = MovedIn
+			paired = append(paired, notification)
+
+		default:
+			paired = append(paired, notification)
+		}
+	}
+	return paired
+}
@@ -0,0 +1,55 @@
+package watch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPairRenames(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []fileNotification
+		want  []fileNotification
+	}{
+		{
+			"windows pair",
+			[]fileNotification{
+				{action: RenamedOldName, path: `C:\a`},
+				{action: RenamedNewName, path: `C:\b`},
+			},
+			[]fileNotification{{action: Renamed, path: `C:\b`, oldPath: `C:\a`}},
+		},
+		{
+			"windows halves",
+			[]fileNotification{
+				{action: RenamedOldName, path: `C:\a`},
+				{action: Added, path: `C:\c`},
+				{action: RenamedNewName, path: `C:\b`},
+			},
+			[]fileNotification{
+				{action: MovedOut, path: `C:\a`},
+				{action: Added, path: `C:\c`},
+				{action: MovedIn, path: `C:\b`},
+			},
+		},
+		{
+			"inotify cookies",
+			[]fileNotification{
+				{action: RenamedOldName, path: "/a", cookie: 7},
+				{action: Modified, path: "/c"},
+				{action: RenamedNewName, path: "/b", cookie: 7},
+			},
+			[]fileNotification{
+				{action: Renamed, path: "/b", oldPath: "/a"},
+				{action: Modified, path: "/c"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pairRenames(test.input); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
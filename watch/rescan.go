@@ -0,0 +1,184 @@
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type treeEntry struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// walkTree lists every entry below root up to depth levels (0 for unlimited), root excluded
+func walkTree(root string, depth int) map[string]treeEntry {
+	entries := make(map[string]treeEntry)
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		entries[path] = treeEntry{size: info.Size(), modTime: info.ModTime(), isDir: entry.IsDir()}
+		if entry.IsDir() && depth > 0 && pathDepth(path, root) >= depth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return entries
+}
+
+// diffTrees returns the notifications turning before into after
+// Directories are only added or removed, their mtime follows their content
+func diffTrees(before map[string]treeEntry, after map[string]treeEntry) []fileNotification {
+	var notifications []fileNotification
+	for path, entry := range after {
+		old, found := before[path]
+		if !found {
+			notifications = append(notifications, fileNotification{action: Added, path: path})
+		} else if !entry.isDir && (old.size != entry.size || !old.modTime.Equal(entry.modTime)) {
+			notifications = append(notifications, fileNotification{action: Modified, path: path})
+		}
+	}
+	for path := range before {
+		if _, found := after[path]; !found {
+			notifications = append(notifications, fileNotification{action: Removed, path: path})
+		}
+	}
+	return notifications
+}
+
+// Cached listing of a watched tree, kept in sync with notifications
+type treeCache struct {
+	mu         sync.Mutex
+	root       string
+	depth      int
+	entries    map[string]treeEntry
+	rescanning atomic.Bool
+}
+
+func newTreeCache(root Root) *treeCache {
+	return &treeCache{root: root.Path, depth: root.MaxDepth(), entries: walkTree(root.Path, root.MaxDepth())}
+}
+
+// forget removes path and, for directories, everything below
+func (c *treeCache) forget(path string) {
+	entry, found := c.entries[path]
+	delete(c.entries, path)
+	if !found || !entry.isDir {
+		return
+	}
+	for child := range c.entries {
+		if isBelow(child, path) {
+			delete(c.entries, child)
+		}
+	}
+}
+
+// learn adds path and, for directories, everything below within the depth limit
+func (c *treeCache) learn(path string) {
+	depth := pathDepth(path, c.root)
+	if depth < 0 || (c.depth > 0 && depth > c.depth) {
+		return
+	}
+	if c.depth == 0 || depth < c.depth {
+		for child, entry := range walkTree(path, max(c.depth-depth, 0)) {
+			c.entries[child] = entry
+		}
+	}
+	if info, err := lstatEntry(path); err == nil {
+		c.entries[path] = info
+	}
+}
+
+func lstatEntry(path string) (treeEntry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return treeEntry{}, err
+	}
+	return treeEntry{size: info.Size(), modTime: info.ModTime(), isDir: info.IsDir()}, nil
+}
+
+func (c *treeCache) update(notifications []fileNotification) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, notification := range pairRenames(notifications) {
+		switch notification.action {
+		case Removed, MovedOut:
+			c.forget(notification.path)
+		case Renamed:
+			c.forget(notification.oldPath)
+			c.learn(notification.path)
+		case Added, MovedIn:
+			c.learn(notification.path)
+		case Modified:
+			if entry, err := lstatEntry(notification.path); err == nil {
+				c.entries[notification.path] = entry
+			}
+		}
+	}
+}
+
+// rescan walks the tree again and returns what changed since the cached listing
+func (c *treeCache) rescan() []fileNotification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := walkTree(c.root, c.depth)
+	notifications := diffTrees(c.entries, current)
+	c.entries = current
+	for i := range notifications {
+		notifications[i].rescan = true
+	}
+	return notifications
+}
+
+// Tree is the cached listing behind Options.Rescan, for sources outside this package (fanotify)
+type Tree struct {
+	root  Root
+	cache *treeCache
+}
+
+// NewTree lists root
+func NewTree(root Root) *Tree {
+	return &Tree{root: root, cache: newTreeCache(root)}
+}
+
+// Seen records the current state of path, reported by the caller, so Rescan doesn't report it again
+func (t *Tree) Seen(path string) {
+	t.cache.mu.Lock()
+	defer t.cache.mu.Unlock()
+	if _, found := t.cache.entries[path]; found {
+		if entry, err := lstatEntry(path); err == nil {
+			t.cache.entries[path] = entry
+		}
+		return
+	}
+	t.cache.learn(path)
+}
+
+// Rescan returns the changes since the listing as events, false when a rescan is already running
+func (t *Tree) Rescan() ([]Event, bool) {
+	if !t.cache.rescanning.CompareAndSwap(false, true) {
+		return nil, false
+	}
+	defer t.cache.rescanning.Store(false)
+
+	givenTime := time.Now()
+	var events []Event
+	for _, notification := range t.cache.rescan() {
+		events = append(events, Event{Time: givenTime, Root: t.root.Path, Kind: "file", Action: notification.action, Path: notification.path, Rescan: true})
+	}
+	return events, true
+}
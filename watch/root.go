@@ -0,0 +1,44 @@
+package watch
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Root is a watched directory, with its own recursion and depth limit
+type Root struct {
+	Path      string
+	Recursive bool
+	Depth     int // levels below Path, 0 for unlimited
+}
+
+// MaxDepth returns how many levels below the root are watched, 0 for unlimited
+func (r Root) MaxDepth() int {
+	if !r.Recursive {
+		return 1
+	}
+	return r.Depth
+}
+
+// Allows tells if path is the root or below it, within the depth limit
+func (r Root) Allows(path string) bool {
+	depth := pathDepth(path, r.Path)
+	return depth >= 0 && (r.MaxDepth() == 0 || depth <= r.MaxDepth())
+}
+
+// pathDepth returns the number of levels between root and path, -1 when path is not below root
+func pathDepth(path string, root string) int {
+	if path == root {
+		return 0
+	}
+	if !isBelow(path, root) {
+		return -1
+	}
+	relative := path[len(strings.TrimRight(root, `\/`))+1:]
+	relative = strings.TrimRight(relative, `\/`)
+	return strings.Count(relative, string(filepath.Separator)) + 1
+}
+
+func isBelow(path string, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimRight(dir, `\/`)+string(filepath.Separator))
+}
@@ -0,0 +1,228 @@
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const Supported = true
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF |
+	unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+// Pseudo filesystems, never watched
+var inotifySkip = []string{"/proc", "/sys", "/dev/pts", "/run/user"}
+
+// Recursive inotify watcher, only used from its read loop
+type inotifyWatcher struct {
+	fd       int
+	root     string
+	maxDepth int // 0 for unlimited
+	out      sink
+	paths    map[int]string // wd -> directory
+	wds      map[string]int // directory -> wd
+	full     bool           // max_user_watches reached, already reported
+}
+
+// startSource runs one inotify instance for root
+func startSource(root Root, bufferSize int, out sink) (func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("can't start inotify (%w)", err)
+	}
+	// Non blocking descriptors go through the runtime poller, closing the file ends a pending read
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	watcher := &inotifyWatcher{
+		fd:       fd,
+		root:     root.Path,
+		maxDepth: root.MaxDepth(),
+		out:      out,
+		paths:    make(map[int]string),
+		wds:      make(map[string]int),
+	}
+
+	watcher.addTree(watcher.root, nil)
+	if len(watcher.paths) == 0 {
+		file.Close()
+		return nil, errors.New("can't open directory")
+	}
+
+	var stopping atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := watcher.run(file, bufferSize)
+		if !stopping.Load() {
+			out.ended(fmt.Errorf("stopped watching %s (%w)", root.Path, err))
+		}
+	}()
+
+	stop := func() {
+		stopping.Store(true)
+		file.Close()
+		<-done
+	}
+	return stop, nil
+}
+
+// run reads until the file is closed
+func (w *inotifyWatcher) run(file *os.File, bufferSize int) error {
+	// At least one event with the longest name
+	buffer := make([]byte, max(bufferSize, unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		bytesReturned, err := file.Read(buffer)
+		if err != nil {
+			return err
+		}
+
+		currentTime := time.Now()
+		var notifications []fileNotification
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= bytesReturned {
+			record := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := nameStart + int(record.Len)
+			if nameEnd > bytesReturned {
+				break
+			}
+			name := strings.TrimRight(string(buffer[nameStart:nameEnd]), "\x00")
+			notifications = w.handleEvent(record, name, notifications)
+			offset = nameEnd
+		}
+		w.out.handle(notifications, currentTime)
+	}
+}
+
+// addTree watches dir and all its subdirectories
+// With found, existing entries are added to it (created before the watch)
+func (w *inotifyWatcher) addTree(dir string, found *[]fileNotification) {
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		// Deeper than the root depth limit
+		depth := pathDepth(path, w.root)
+		if w.maxDepth > 0 && depth > w.maxDepth {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if found != nil && path != dir {
+			*found = append(*found, fileNotification{action: Added, path: path})
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+		for _, skip := range inotifySkip {
+			if path == skip {
+				return filepath.SkipDir
+			}
+		}
+
+		// Its entries would be too deep
+		if w.maxDepth > 0 && depth >= w.maxDepth {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err != nil {
+			if errors.Is(err, unix.ENOSPC) && !w.full {
+				w.full = true
+				w.out.warn(errors.New("inotify watch limit reached, see /proc/sys/fs/inotify/max_user_watches"))
+			}
+			return filepath.SkipDir
+		}
+		w.paths[wd] = path
+		w.wds[path] = wd
+		return nil
+	})
+}
+
+// removeTree forgets dir and its subdirectories, used when a directory is moved away
+func (w *inotifyWatcher) removeTree(dir string) {
+	for path, wd := range w.wds {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.wds, path)
+			delete(w.paths, wd)
+		}
+	}
+}
+
+// handleEvent keeps watches in sync and appends the notifications of an inotify record
+func (w *inotifyWatcher) handleEvent(record *unix.InotifyEvent, name string, notifications []fileNotification) []fileNotification {
+	wd := int(record.Wd)
+	mask := record.Mask
+
+	// Queue overflow, watch directories created meanwhile and recover from the cache
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.addTree(w.root, nil)
+		return append(notifications, fileNotification{action: Overflow, path: w.root})
+	}
+
+	dir, found := w.paths[wd]
+	if !found {
+		return notifications
+	}
+
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.paths, wd)
+		if w.wds[dir] == wd {
+			delete(w.wds, dir)
+		}
+		return notifications
+	}
+
+	// Watched directory removed, its parent reports it
+	if mask&unix.IN_DELETE_SELF != 0 {
+		return notifications
+	}
+
+	fullname := filepath.Join(dir, name)
+	isDir := mask&unix.IN_ISDIR != 0
+
+	var action Action
+	switch {
+	case mask&unix.IN_CREATE != 0:
+		action = Added
+	case mask&unix.IN_DELETE != 0:
+		action = Removed
+	case mask&unix.IN_MOVED_FROM != 0:
+		action = RenamedOldName
+	case mask&unix.IN_MOVED_TO != 0:
+		action = RenamedNewName
+	case mask&(unix.IN_MODIFY|unix.IN_ATTRIB) != 0:
+		action = Modified
+	default:
+		return notifications
+	}
+
+	// Keep the watch list in sync with the tree, entries of a new directory come first
+	if isDir {
+		switch action {
+		case Added:
+			w.addTree(fullname, &notifications)
+		case RenamedNewName:
+			w.addTree(fullname, nil)
+		case RenamedOldName:
+			w.removeTree(fullname)
+		}
+	}
+
+	return append(notifications, fileNotification{action: action, path: fullname, cookie: record.Cookie})
+}
@@ -0,0 +1,17 @@
+//go:build !windows && !linux
+
+package watch
+
+const Supported = false
+
+func startSource(root Root, bufferSize int, out sink) (func(), error) {
+	return nil, ErrUnsupported
+}
+
+func pathOwner(path string, names *Names) string {
+	return ""
+}
+
+func pathAccess(path string) string {
+	return "--"
+}
@@ -0,0 +1,284 @@
+package watch
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const Supported = true
+
+const (
+	FILE_NOTIFY_CHANGE_FILE_NAME   = 0x00000001
+	FILE_NOTIFY_CHANGE_DIR_NAME    = 0x00000002
+	FILE_NOTIFY_CHANGE_ATTRIBUTES  = 0x00000004
+	FILE_NOTIFY_CHANGE_SIZE        = 0x00000008
+	FILE_NOTIFY_CHANGE_LAST_WRITE  = 0x00000010
+	FILE_NOTIFY_CHANGE_LAST_ACCESS = 0x00000020
+	FILE_NOTIFY_CHANGE_CREATION    = 0x00000040
+	FILE_NOTIFY_CHANGE_SECURITY    = 0x00000100
+)
+
+const watchedChanges = FILE_NOTIFY_CHANGE_FILE_NAME | FILE_NOTIFY_CHANGE_DIR_NAME |
+	FILE_NOTIFY_CHANGE_ATTRIBUTES | FILE_NOTIFY_CHANGE_SIZE |
+	FILE_NOTIFY_CHANGE_LAST_WRITE | FILE_NOTIFY_CHANGE_CREATION
+
+// Batches waiting for a slow consumer, past this they are dropped and reported as an overflow
+const deliveryQueue = 64
+
+// One watched directory, with its pending overlapped read
+// buffer and overlapped belong to the kernel while a read is pending, watches stay referenced meanwhile
+type dirWatch struct {
+	key        uintptr
+	root       Root
+	handle     windows.Handle
+	prefix     string
+	out        sink
+	buffer     []byte
+	overlapped windows.Overlapped
+	removed    bool // cancelled by stop
+	closed     bool // handle closed by the loop
+
+	// The loop never waits for the consumer, deliver hands batches to a goroutine per watch
+	queue   chan delivery // closed by forget, the loop is its only sender
+	lost    atomic.Bool   // a batch didn't fit in queue
+	stopped chan struct{} // closed by stop, pending batches are dropped
+	endErr  error         // why the watch stopped on its own, set before queue is closed
+}
+
+// delivery is what one completion produced
+type delivery struct {
+	notifications []fileNotification
+	givenTime     time.Time
+	warn          error
+}
+
+// dirWatcher serves every watched directory with overlapped ReadDirectoryChangesW
+// and a single I/O completion port, watches can be added and removed at runtime
+type dirWatcher struct {
+	mu      sync.Mutex
+	port    windows.Handle
+	watches map[uintptr]*dirWatch
+	nextKey uintptr
+}
+
+// Shared by every Watcher, started on first use and never closed
+var sharedWatcher = sync.OnceValues(func() (*dirWatcher, error) {
+	port, err := windows.CreateIoCompletionPort(windows.InvalidHandle, 0, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("completion port: %w", err)
+	}
+	watcher := &dirWatcher{
+		port:    port,
+		watches: make(map[uintptr]*dirWatch),
+		nextKey: 1,
+	}
+	go watcher.run()
+	return watcher, nil
+})
+
+// startSource adds root to the shared completion port
+func startSource(root Root, bufferSize int, out sink) (func(), error) {
+	watcher, err := sharedWatcher()
+	if err != nil {
+		return nil, err
+	}
+	watch, err := watcher.add(root, bufferSize, out)
+	if err != nil {
+		return nil, err
+	}
+	return func() { watcher.stop(watch) }, nil
+}
+
+func (w *dirWatcher) add(root Root, bufferSize int, out sink) (*dirWatch, error) {
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(root.Path),
+		windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED,
+		0,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("opening directory %s: %w", root.Path, err)
+	}
+
+	// Notifications hold names relative to the root, UNC roots may lack the trailing separator
+	prefix := root.Path
+	if !strings.HasSuffix(prefix, `\`) {
+		prefix += `\`
+	}
+
+	watch := &dirWatch{
+		root:    root,
+		handle:  handle,
+		prefix:  prefix,
+		out:     out,
+		buffer:  make([]byte, bufferSize),
+		queue:   make(chan delivery, deliveryQueue),
+		stopped: make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	watch.key = w.nextKey
+	w.nextKey++
+	if _, err := windows.CreateIoCompletionPort(handle, w.port, watch.key, 0); err != nil {
+		windows.CloseHandle(handle)
+		return nil, fmt.Errorf("completion port for %s: %w", root.Path, err)
+	}
+	if err := watch.read(); err != nil {
+		windows.CloseHandle(handle)
+		return nil, fmt.Errorf("watching %s: %w", root.Path, err)
+	}
+	w.watches[watch.key] = watch
+	go watch.deliverAll()
+	return watch, nil
+}
+
+// stop cancels the pending read without waiting, the loop closes the handle once it completes
+// Nothing is delivered after stop returns
+func (w *dirWatcher) stop(watch *dirWatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if watch.removed {
+		return
+	}
+	watch.removed = true
+	close(watch.stopped)
+	// Once closed, the handle value may belong to someone else
+	if !watch.closed {
+		windows.CancelIoEx(watch.handle, &watch.overlapped)
+	}
+}
+
+// read queues the next overlapped read
+func (watch *dirWatch) read() error {
+	watch.overlapped = windows.Overlapped{}
+	return windows.ReadDirectoryChanges(
+		watch.handle,
+		&watch.buffer[0],
+		uint32(len(watch.buffer)),
+		watch.root.Recursive,
+		watchedChanges,
+		nil,
+		&watch.overlapped,
+		0,
+	)
+}
+
+// forget needs the lock, err is reported unless the watch was stopped
+func (w *dirWatcher) forget(watch *dirWatch, err error) {
+	delete(w.watches, watch.key)
+	windows.CloseHandle(watch.handle)
+	watch.closed = true
+	if !watch.removed {
+		watch.endErr = err
+	}
+	close(watch.queue)
+}
+
+// deliver never blocks, a full queue is reported as an overflow once the consumer catches up
+func (watch *dirWatch) deliver(batch delivery) {
+	select {
+	case watch.queue <- batch:
+	default:
+		watch.lost.Store(true)
+	}
+}
+
+// deliverAll hands the batches of the loop to the sink, until the watch ends or is stopped
+func (watch *dirWatch) deliverAll() {
+	for {
+		select {
+		case <-watch.stopped:
+			return
+		case batch, ok := <-watch.queue:
+			if !ok {
+				if watch.endErr != nil {
+					watch.out.ended(watch.endErr)
+				}
+				return
+			}
+			if batch.warn != nil {
+				watch.out.warn(batch.warn)
+			}
+			watch.out.handle(batch.notifications, batch.givenTime)
+			if watch.lost.Swap(false) {
+				watch.out.handle([]fileNotification{{action: Overflow, path: watch.root.Path}}, time.Now())
+			}
+		}
+	}
+}
+
+// run handles completions for the life of the process
+func (w *dirWatcher) run() {
+	for {
+		var bytesReturned uint32
+		var key uintptr
+		var overlapped *windows.Overlapped
+		err := windows.GetQueuedCompletionStatus(w.port, &bytesReturned, &key, &overlapped, windows.INFINITE)
+		if overlapped == nil && err != nil {
+			// Port broken, nothing will ever complete again
+			w.mu.Lock()
+			for _, watch := range w.watches {
+				w.forget(watch, fmt.Errorf("watcher error: %w", err))
+			}
+			w.mu.Unlock()
+			return
+		}
+
+		w.mu.Lock()
+		watch := w.watches[key]
+		if watch == nil {
+			w.mu.Unlock()
+			continue
+		}
+
+		// Stopped, or the directory is gone (drive unplugged)
+		if watch.removed || (err != nil && err != windows.ERROR_NOTIFY_ENUM_DIR) {
+			w.forget(watch, fmt.Errorf("stopped watching %s (%w)", watch.root.Path, err))
+			w.mu.Unlock()
+			continue
+		}
+
+		// Copy out before the buffer is handed back to the kernel
+		currentTime := time.Now()
+		overflow := err == windows.ERROR_NOTIFY_ENUM_DIR || bytesReturned == 0
+		var notifications []fileNotification
+		var decodeErr error
+		if overflow {
+			notifications = []fileNotification{{action: Overflow, path: watch.root.Path}}
+		} else {
+			notifications, decodeErr = watch.parse(bytesReturned)
+		}
+		batch := delivery{notifications: notifications, givenTime: currentTime}
+		if decodeErr != nil {
+			batch.warn = fmt.Errorf("bad notification from %s (%w)", watch.root.Path, decodeErr)
+		}
+		watch.deliver(batch)
+		if err := watch.read(); err != nil {
+			w.forget(watch, fmt.Errorf("stopped watching %s (%w)", watch.root.Path, err))
+		}
+		w.mu.Unlock()
+	}
+}
+
+// parse decodes the records of the last read, the error tells why the rest was dropped
+func (watch *dirWatch) parse(bytesReturned uint32) ([]fileNotification, error) {
+	records, err := decodeNotifyInformation(watch.buffer[:min(bytesReturned, uint32(len(watch.buffer)))])
+	var notifications []fileNotification
+	for _, record := range records {
+		fullname := watch.prefix + record.name
+		// Skip entries deeper than the root depth limit
+		if watch.root.Allows(fullname) {
+			notifications = append(notifications, fileNotification{action: record.action, path: fullname})
+		}
+	}
+	return notifications, err
+}
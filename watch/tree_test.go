@@ -0,0 +1,40 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTreeRescan(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept")
+	gone := filepath.Join(dir, "gone")
+	os.WriteFile(kept, []byte("a"), 0o600)
+	os.WriteFile(gone, []byte("a"), 0o600)
+	tree := NewTree(Root{Path: dir, Recursive: true})
+
+	// A file the source reported, and changes it missed
+	seen := filepath.Join(dir, "seen")
+	os.WriteFile(seen, []byte("a"), 0o600)
+	tree.Seen(seen)
+	missed := filepath.Join(dir, "missed")
+	os.WriteFile(missed, []byte("a"), 0o600)
+	os.Remove(gone)
+
+	events, ran := tree.Rescan()
+	if !ran {
+		t.Fatal("rescan didn't run")
+	}
+	got := make(map[string]Action)
+	for _, event := range events {
+		if !event.Rescan || event.Root != dir {
+			t.Errorf("event %+v", event)
+		}
+		got[event.Path] = event.Action
+	}
+	want := map[string]Action{missed: Added, gone: Removed}
+	if len(got) != len(want) || got[missed] != Added || got[gone] != Removed {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
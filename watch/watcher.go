@@ -0,0 +1,260 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is a change below a watched root
+type Event struct {
+	Time    time.Time
+	Root    string // path of the root reporting it
+	Kind    string // file or dir, dir is only known for overflows or with Options.Details
+	Action  Action
+	Path    string
+	OldPath string // Renamed only
+	Owner   string // Options.Details only
+	Access  string // RW, R-, -W or --, Options.Details only
+	Rescan  bool   // recovered by a rescan after an overflow
+}
+
+// Options of a Watcher, the zero value is fine
+type Options struct {
+	BufferSize int         // bytes read at once, 4096 when 0
+	Rescan     bool        // keep a listing of each root and rescan it after an overflow
+	Details    bool        // fill Kind, Owner and Access, a stat and a few opens per event
+	Names      *Names      // owner names with Details, a cache waiting a second per lookup when nil
+	Warn       func(error) // watches stopping on their own, inotify limits ...
+}
+
+// Watcher sends the events of its roots on a single channel
+type Watcher struct {
+	options Options
+	events  chan Event
+	done    chan struct{}
+	sending sync.RWMutex // held for reading while sending, Close takes it to close events
+
+	mu     sync.Mutex
+	roots  map[string]*rootWatch
+	closed bool
+}
+
+type rootWatch struct {
+	root  Root
+	cache *treeCache
+	stop  func()
+}
+
+// sink receives what a source reads from a root
+type sink struct {
+	handle func(notifications []fileNotification, givenTime time.Time)
+	warn   func(error)
+	ended  func(error) // the watch stopped on its own (directory gone ...)
+}
+
+var errClosed = errors.New("watcher closed")
+
+var ErrUnsupported = errors.New("watching is only supported on Linux and Windows")
+
+func New(options Options) *Watcher {
+	if options.BufferSize <= 0 {
+		options.BufferSize = 4096
+	}
+	if options.Names == nil {
+		options.Names = NewNames(time.Second)
+	}
+	return &Watcher{
+		options: options,
+		events:  make(chan Event),
+		done:    make(chan struct{}),
+		roots:   make(map[string]*rootWatch),
+	}
+}
+
+// Events is closed by Close
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Add starts watching root, a root already watched is left as is
+func (w *Watcher) Add(root Root) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errClosed
+	}
+	if _, found := w.roots[root.Path]; found {
+		return nil
+	}
+
+	watch := &rootWatch{root: root}
+	if w.options.Rescan {
+		watch.cache = newTreeCache(root)
+	}
+	stop, err := startSource(root, w.options.BufferSize, sink{
+		handle: func(notifications []fileNotification, givenTime time.Time) {
+			w.handle(watch, notifications, givenTime)
+		},
+		warn: w.warn,
+		ended: func(err error) {
+			w.mu.Lock()
+			if w.roots[root.Path] == watch {
+				delete(w.roots, root.Path)
+			}
+			w.mu.Unlock()
+			w.warn(err)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	watch.stop = stop
+	w.roots[root.Path] = watch
+	return nil
+}
+
+// Remove stops watching path, without waiting for the source to let go of it
+func (w *Watcher) Remove(path string) {
+	w.mu.Lock()
+	watch := w.roots[path]
+	delete(w.roots, path)
+	w.mu.Unlock()
+	if watch != nil {
+		go watch.stop()
+	}
+}
+
+// Roots returns the watched paths
+func (w *Watcher) Roots() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var paths []string
+	for path := range w.roots {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Close stops every root and closes Events once no event is being sent
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	roots := w.roots
+	w.roots = nil
+	w.mu.Unlock()
+
+	// Senders give up first, so stopping a root never waits for a consumer
+	close(w.done)
+	for _, watch := range roots {
+		watch.stop()
+	}
+	w.sending.Lock()
+	close(w.events)
+	w.sending.Unlock()
+}
+
+func (w *Watcher) warn(err error) {
+	if err != nil && w.options.Warn != nil {
+		w.options.Warn(err)
+	}
+}
+
+// handle pairs renames and sends a batch, overflows are reported and recovered apart
+func (w *Watcher) handle(watch *rootWatch, notifications []fileNotification, givenTime time.Time) {
+	var changes []fileNotification
+	for _, notification := range notifications {
+		if notification.action == Overflow {
+			go w.overflow(watch)
+			continue
+		}
+		changes = append(changes, notification)
+	}
+	watch.cache.update(changes)
+	for _, notification := range pairRenames(changes) {
+		if !w.send(watch, notification, givenTime) {
+			return
+		}
+	}
+}
+
+// overflow reports the lost events and recovers them from the listing when there is one
+func (w *Watcher) overflow(watch *rootWatch) {
+	event := Event{Time: time.Now(), Root: watch.root.Path, Kind: "dir", Action: Overflow, Path: watch.root.Path}
+	if !w.sendEvent(event) {
+		return
+	}
+
+	// One rescan at a time, a running rescan also covers this overflow
+	cache := watch.cache
+	if cache == nil || !cache.rescanning.CompareAndSwap(false, true) {
+		return
+	}
+	defer cache.rescanning.Store(false)
+
+	givenTime := time.Now()
+	for _, notification := range cache.rescan() {
+		if !w.send(watch, notification, givenTime) {
+			return
+		}
+	}
+}
+
+func (w *Watcher) send(watch *rootWatch, notification fileNotification, givenTime time.Time) bool {
+	event := Event{
+		Time:    givenTime,
+		Root:    watch.root.Path,
+		Kind:    "file",
+		Action:  notification.action,
+		Path:    notification.path,
+		OldPath: notification.oldPath,
+		Rescan:  notification.rescan,
+	}
+	if w.options.Details && event.Action != Removed && event.Action != MovedOut {
+		if info, err := os.Stat(event.Path); err == nil {
+			if info.IsDir() {
+				event.Kind = "dir"
+			} else {
+				event.Access = pathAccess(event.Path)
+			}
+			event.Owner = pathOwner(event.Path, w.options.Names)
+		}
+	}
+	return w.sendEvent(event)
+}
+
+// sendEvent returns false once the watcher is closed
+func (w *Watcher) sendEvent(event Event) bool {
+	w.sending.RLock()
+	defer w.sending.RUnlock()
+	select {
+	case <-w.done:
+		return false
+	default:
+	}
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// accessString returns RW, R-, -W or --
+func accessString(read bool, write bool) string {
+	access := "-"
+	if read {
+		access = "R"
+	}
+	if write {
+		return access + "W"
+	}
+	return access + "-"
+}
@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for the next event of path, others are skipped
+func nextEvent(t *testing.T, watcher *Watcher, path string) Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				t.Fatalf("events closed while waiting for %s", path)
+			}
+			if event.Path == path {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no event for %s", path)
+		}
+	}
+}
+
+func TestWatcherEvents(t *testing.T) {
+	dir := t.TempDir()
+	watcher := New(Options{Details: true})
+	defer watcher.Close()
+	if err := watcher.Add(Root{Path: dir, Recursive: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "run.sh")
+	if err := os.WriteFile(file, []byte("id"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	event := nextEvent(t, watcher, file)
+	if event.Action != Added || event.Kind != "file" || event.Root != dir {
+		t.Errorf("got %+v, want an added file below %s", event, dir)
+	}
+	if event.Access != "RW" || event.Owner == "" {
+		t.Errorf("got access %q owner %q, want RW and an owner", event.Access, event.Owner)
+	}
+
+	renamed := filepath.Join(dir, "run.tmp")
+	if err := os.Rename(file, renamed); err != nil {
+		t.Fatal(err)
+	}
+	event = nextEvent(t, watcher, renamed)
+	if event.Action != Renamed || event.OldPath != file {
+		t.Errorf("got %+v, want %s renamed from %s", event, renamed, file)
+	}
+}
+
+func TestWatcherNewDirectory(t *testing.T) {
+	dir := t.TempDir()
+	watcher := New(Options{})
+	defer watcher.Close()
+	if err := watcher.Add(Root{Path: dir, Recursive: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, watcher, sub)
+
+	// The new directory is watched too
+	file := filepath.Join(sub, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, watcher, file); event.Action != Added {
+		t.Errorf("got %v, want added", event.Action)
+	}
+}
+
+func TestWatcherClose(t *testing.T) {
+	watcher := New(Options{})
+	if err := watcher.Add(Root{Path: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	if roots := watcher.Roots(); len(roots) != 1 {
+		t.Errorf("got roots %v, want one", roots)
+	}
+	watcher.Close()
+	if _, ok := <-watcher.Events(); ok {
+		t.Error("events still open after Close")
+	}
+	if err := watcher.Add(Root{Path: t.TempDir()}); err == nil {
+		t.Error("Add after Close succeeded")
+	}
+}
+
+func TestWatcherCloseUndrained(t *testing.T) {
+	dir := t.TempDir()
+	stalled := New(Options{})
+	if err := stalled.Add(Root{Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+	drained := New(Options{})
+	defer drained.Close()
+	if err := drained.Add(Root{Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody reads stalled, drained still gets its events
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, drained, file)
+
+	closed := make(chan struct{})
+	go func() {
+		stalled.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on an undrained watcher")
+	}
+}